@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// accountReport is one row of the accounts report.
+type accountReport struct {
+	Name             string `json:"name"`
+	Type             string `json:"type"`
+	Description      string `json:"description"`
+	CreditLimit      string `json:"creditLimit"`
+	StatementBalance string `json:"statementBalance"`
+	StatementDate    string `json:"statementDate"`
+	Transactions     int    `json:"transactions"`
+	FirstTransaction string `json:"firstTransaction"`
+	LastTransaction  string `json:"lastTransaction"`
+	EndingBalance    string `json:"endingBalance"`
+}
+
+// buildAccountReport summarizes each parsed account. The ending balance is the
+// sum of every register amount, which includes the Opening Balance entry
+// Quicken writes as the first transaction of an account.
+func buildAccountReport(qif *qifFile) []accountReport {
+	var rows []accountReport
+	for _, account := range qif.Accounts {
+		if account.Name == "" {
+			continue
+		}
+		row := accountReport{
+			Name:             account.Name,
+			Type:             account.Type,
+			Description:      account.Description,
+			CreditLimit:      account.CreditLimit,
+			StatementBalance: account.Balance,
+			StatementDate:    account.BalanceDate,
+			Transactions:     len(account.Transactions),
+		}
+		if row.StatementDate != "" {
+			if date, err := parseQIFDate(row.StatementDate); err == nil {
+				row.StatementDate = date.Format("2006-01-02")
+			}
+		}
+
+		var balance money
+		for _, t := range account.Transactions {
+			balance += t.Amount
+
+			if t.Date.IsZero() {
+				continue
+			}
+			date := t.Date.Format("2006-01-02")
+			if row.FirstTransaction == "" || date < row.FirstTransaction {
+				row.FirstTransaction = date
+			}
+			if date > row.LastTransaction {
+				row.LastTransaction = date
+			}
+		}
+		row.EndingBalance = balance.String()
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// writeAccountReport writes the accounts report as CSV or JSON.
+func writeAccountReport(rows []accountReport, outputFileName string, format string) error {
+	// Check the format first so a bad one does not leave an empty file behind
+	if format != "csv" && format != "json" {
+		return fmt.Errorf("unknown account report format: %s", format)
+	}
+	outputFile, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(outputFile)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	case "csv":
+		writer := csv.NewWriter(outputFile)
+		writer.Write([]string{"Name", "Type", "Description", "Credit Limit", "Statement Balance", "Statement Date", "Transactions", "First Transaction", "Last Transaction", "Ending Balance"})
+		for _, row := range rows {
+			writer.Write([]string{
+				row.Name,
+				row.Type,
+				row.Description,
+				row.CreditLimit,
+				row.StatementBalance,
+				row.StatementDate,
+				strconv.Itoa(row.Transactions),
+				row.FirstTransaction,
+				row.LastTransaction,
+				row.EndingBalance,
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return nil
+}
+
+func extractAccountReport(inputFileNames []string, outputFileName string, format string) error {
+	qif, err := loadQIFFiles(inputFileNames, nil)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return err
+	}
+
+	rows := buildAccountReport(qif)
+	err = writeAccountReport(rows, outputFileName, format)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Extracted Account: ", len(rows))
+
+	return nil
+}
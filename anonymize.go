@@ -0,0 +1,340 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// anonymizer replaces identifying values with pseudonyms. The same original
+// value always gets the same pseudonym, so matching payees, transfers
+// between accounts and duplicate transactions still line up.
+type anonymizer struct {
+	names     map[string]map[string]string
+	jitter    float64
+	shiftDays int
+	seed      int64
+}
+
+func newAnonymizer(jitter float64, shiftDays int, seed int64) *anonymizer {
+	return &anonymizer{names: make(map[string]map[string]string), jitter: jitter, shiftDays: shiftDays, seed: seed}
+}
+
+// pseudonym returns "<kind> <n>" for the nth distinct value of a kind.
+func (a *anonymizer) pseudonym(kind string, value string) string {
+	if value == "" {
+		return value
+	}
+	if a.names[kind] == nil {
+		a.names[kind] = make(map[string]string)
+	}
+	if name, ok := a.names[kind][value]; ok {
+		return name
+	}
+	name := fmt.Sprintf("%s %d", kind, len(a.names[kind])+1)
+	a.names[kind][value] = name
+	return name
+}
+
+// checkNumber keeps words like ATM, EFT or DEP and renumbers real check
+// numbers from 1001.
+func (a *anonymizer) checkNumber(value string) string {
+	if value == "" || strings.Trim(value, "0123456789") != "" {
+		return value
+	}
+	if a.names["Number"] == nil {
+		a.names["Number"] = make(map[string]string)
+	}
+	if number, ok := a.names["Number"][value]; ok {
+		return number
+	}
+	number := strconv.Itoa(1001 + len(a.names["Number"]))
+	a.names["Number"][value] = number
+	return number
+}
+
+// category renames the account in a transfer category and leaves other
+// categories alone; a "/class" suffix is kept.
+func (a *anonymizer) category(value string) string {
+	if strings.HasPrefix(value, "[") {
+		if end := strings.Index(value, "]"); end > 0 {
+			return "[" + a.pseudonym("Account", value[1:end]) + "]" + value[end+1:]
+		}
+	}
+	return value
+}
+
+// date shifts a QIF date by shiftDays, keeping four digit years as they were.
+func (a *anonymizer) date(value string) string {
+	if a.shiftDays == 0 {
+		return value
+	}
+	date, err := parseQIFDate(value)
+	if err != nil {
+		return value
+	}
+	date = date.AddDate(0, 0, a.shiftDays)
+	parts := strings.Split(strings.ReplaceAll(strings.ReplaceAll(value, " ", ""), "'", "/"), "/")
+	if len(parts) == 3 && len(parts[2]) == 4 {
+		return fmt.Sprintf("%d/%d/%04d", int(date.Month()), date.Day(), date.Year())
+	}
+	return formatQIFDate(date)
+}
+
+// factor is the amount multiplier for a transaction. It is derived from the
+// seed, date and size of the amount, so both sides of a transfer and exact
+// duplicates are changed by the same factor.
+func (a *anonymizer) factor(rawDate string, amount money) float64 {
+	if a.jitter == 0 {
+		return 1
+	}
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d|%s|%d", a.seed, strings.ReplaceAll(rawDate, " ", ""), int64(amount.Abs()))
+	unit := float64(hash.Sum64()%1000001) / 1000000
+	return 1 + a.jitter/100*(2*unit-1)
+}
+
+// amount scales a single amount, such as an account balance or credit limit,
+// by its factor. Unreadable amounts are left as they are.
+func (a *anonymizer) amount(rawDate string, value string) string {
+	amount, err := parseMoney(value)
+	if err != nil {
+		return value
+	}
+	factor := a.factor(rawDate, amount)
+	if factor == 1 {
+		return value
+	}
+	return money(float64(amount)*factor + 0.5*sign(amount)).String()
+}
+
+// runAnonymize is the anonymize subcommand: it writes a copy of a QIF file
+// that is safe to attach to a bug report.
+func runAnonymize(args []string) {
+	anonymizeCmd := flag.NewFlagSet("anonymize", flag.ExitOnError)
+	inputFile := anonymizeCmd.String("inputfile", "", "QIF file to anonymize")
+	outputFile := anonymizeCmd.String("outputfile", "anonymized.qif", "anonymized QIF file")
+	jitter := anonymizeCmd.Float64("jitter", 0, "change amounts by up to this percent (0 = keep amounts)")
+	shiftDays := anonymizeCmd.Int("shiftdays", 0, "move every date by this many days")
+	seed := anonymizeCmd.Int64("seed", 1, "seed for the amount jitter")
+	anonymizeCmd.Parse(args)
+	fmt.Println("subcommand 'anonymize'")
+	fmt.Println("	inputfile:", *inputFile)
+	fmt.Println("	outputfile:", *outputFile)
+	fmt.Println("	jitter:", *jitter)
+	fmt.Println("	shiftdays:", *shiftDays)
+	fmt.Println("	seed:", *seed)
+
+	inputBytes, err := os.ReadFile(*inputFile)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+	fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
+
+	output := anonymizeQIF(string(inputBytes), newAnonymizer(*jitter, *shiftDays, *seed))
+	err = os.WriteFile(*outputFile, []byte(output), 0644)
+	if err != nil {
+		fmt.Println("Error writing file:", err)
+		return
+	}
+	fmt.Println("Anonymized file written:", *outputFile)
+}
+
+// anonymizeQIF rewrites the file record by record. Headers, field order,
+// categories, tags, classes, securities and line endings are left as they
+// are, so the output parses the same way as the original.
+func anonymizeQIF(content string, a *anonymizer) string {
+	newline := "\n"
+	if strings.Contains(content, "\r\n") {
+		newline = "\r\n"
+	}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	var out []string
+	var record []string
+	section := ""
+	flush := func() {
+		out = append(out, a.record(section, record)...)
+		record = nil
+	}
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "!"):
+			flush()
+			header := strings.TrimSpace(line[1:])
+			section = header
+			if strings.HasPrefix(header, "Type:") {
+				section = strings.TrimSpace(header[len("Type:"):])
+			}
+			out = append(out, line)
+		case strings.TrimSpace(line) == "^":
+			flush()
+			out = append(out, line)
+		default:
+			record = append(record, line)
+		}
+	}
+	flush()
+	return strings.Join(out, newline)
+}
+
+// record anonymizes the lines of one record according to its section.
+func (a *anonymizer) record(section string, record []string) []string {
+	switch {
+	case section == "Account":
+		var balanceDate string
+		for _, line := range record {
+			if line != "" && line[0] == '/' {
+				balanceDate = strings.TrimSpace(line[1:])
+			}
+		}
+		return a.rewriteFields(record, func(code byte, value string) string {
+			switch code {
+			case 'N':
+				return a.pseudonym("Account", value)
+			case 'D':
+				return a.pseudonym("Description", value)
+			case '/':
+				return a.date(value)
+			case '$', 'L':
+				return a.amount(balanceDate, value)
+			}
+			return value
+		})
+	case registerTypes[section] || section == "Memorized":
+		return a.transactionRecord(record)
+	case section == "Invst":
+		// Amounts are left alone here; changing them would break the link
+		// between price, quantity and total
+		return a.rewriteFields(record, func(code byte, value string) string {
+			switch code {
+			case 'D':
+				return a.date(value)
+			case 'P':
+				return a.pseudonym("Payee", value)
+			case 'M':
+				return a.pseudonym("Memo", value)
+			case 'L':
+				return a.category(value)
+			}
+			return value
+		})
+	case section == "Prices":
+		var out []string
+		for _, line := range record {
+			parts := strings.Split(line, ",")
+			if len(parts) >= 3 {
+				date := strings.Trim(strings.TrimSpace(strings.Join(parts[2:], ",")), "\"")
+				line = strings.Join(parts[:2], ",") + ",\"" + a.date(date) + "\""
+			}
+			out = append(out, line)
+		}
+		return out
+	}
+	return record
+}
+
+// rewriteFields applies rewrite to each field line's value. Lines whose
+// value does not change are kept byte for byte.
+func (a *anonymizer) rewriteFields(record []string, rewrite func(code byte, value string) string) []string {
+	var out []string
+	for _, line := range record {
+		if line == "" {
+			out = append(out, line)
+			continue
+		}
+		value := strings.TrimSpace(line[1:])
+		if rewritten := rewrite(line[0], value); rewritten != value {
+			line = line[:1] + rewritten
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// transactionRecord anonymizes a register or memorized transaction. With
+// jitter, the total and the splits are scaled by the same factor and the
+// last split takes up the rounding so the splits still add up.
+func (a *anonymizer) transactionRecord(record []string) []string {
+	var rawDate, rawAmount string
+	for _, line := range record {
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'D':
+			rawDate = strings.TrimSpace(line[1:])
+		case 'T', 'U':
+			if rawAmount == "" {
+				rawAmount = strings.TrimSpace(line[1:])
+			}
+		}
+	}
+	amount, err := parseMoney(rawAmount)
+	factor := 1.0
+	if err == nil {
+		factor = a.factor(rawDate, amount)
+	}
+	total := money(float64(amount)*factor + 0.5*sign(amount))
+
+	// Scaled split amounts, with the rounding difference on the last one
+	var splitAmounts []money
+	for _, line := range record {
+		if line != "" && line[0] == '$' {
+			value, err := parseMoney(line[1:])
+			if err != nil {
+				// Leave a record with unreadable amounts as it is
+				factor = 1
+			}
+			splitAmounts = append(splitAmounts, money(float64(value)*factor+0.5*sign(value)))
+		}
+	}
+	if factor != 1 && len(splitAmounts) > 0 {
+		var sum money
+		for _, value := range splitAmounts {
+			sum += value
+		}
+		splitAmounts[len(splitAmounts)-1] += total - sum
+	}
+
+	split := 0
+	return a.rewriteFields(record, func(code byte, value string) string {
+		switch code {
+		case 'D':
+			return a.date(value)
+		case 'T', 'U':
+			if factor != 1 {
+				return total.String()
+			}
+		case '$':
+			if factor != 1 && split < len(splitAmounts) {
+				split++
+				return splitAmounts[split-1].String()
+			}
+		case 'N':
+			return a.checkNumber(value)
+		case 'P':
+			return a.pseudonym("Payee", value)
+		case 'M', 'E':
+			return a.pseudonym("Memo", value)
+		case 'A':
+			return a.pseudonym("Address", value)
+		case 'L', 'S':
+			return a.category(value)
+		}
+		return value
+	})
+}
+
+// sign is -1 for negative amounts and 1 otherwise, for rounding away from
+// zero.
+func sign(amount money) float64 {
+	if amount < 0 {
+		return -1
+	}
+	return 1
+}
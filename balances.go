@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// runBalances is the balances subcommand: it writes the running balance of
+// each account per day or month end, and optionally a net worth series.
+func runBalances(args []string) {
+	balancesCmd := flag.NewFlagSet("balances", flag.ExitOnError)
+	var inputFile inputFileList
+	balancesCmd.Var(&inputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
+	outputFile := balancesCmd.String("outputfile", "balances.csv", "Date,Account,Balance CSV")
+	interval := balancesCmd.String("interval", "monthly", "balance dates: daily or monthly (month end)")
+	netWorthFile := balancesCmd.String("networth", "", "also write a Date,Assets,Liabilities,Net Worth CSV to this file")
+	accountMapFile := balancesCmd.String("accountmap", "", "account mapping file")
+	accounts := balancesCmd.String("accounts", "", "only these accounts (comma separated names, types or globs)")
+	excludeAccounts := balancesCmd.String("exclude-accounts", "", "leave out these accounts (comma separated names, types or globs)")
+	from := balancesCmd.String("from", "", "first balance date to write (YYYY-MM-DD)")
+	to := balancesCmd.String("to", "", "last balance date to write (YYYY-MM-DD)")
+	dateFormat := balancesCmd.String("dateformat", "iso", "date format: iso, us, eu, excel or a Go layout")
+	balancesCmd.Parse(args)
+	fmt.Println("subcommand 'balances'")
+	fmt.Println("	inputfile:", inputFile.String())
+	fmt.Println("	outputfile:", *outputFile)
+	fmt.Println("	interval:", *interval)
+	fmt.Println("	networth:", *netWorthFile)
+	fmt.Println("	accountmap:", *accountMapFile)
+	fmt.Println("	accounts:", *accounts)
+	fmt.Println("	exclude-accounts:", *excludeAccounts)
+	fmt.Println("	from:", *from)
+	fmt.Println("	to:", *to)
+	fmt.Println("	dateformat:", *dateFormat)
+
+	if *interval != "daily" && *interval != "monthly" {
+		fmt.Println("unknown balance interval:", *interval, "(expected daily or monthly)")
+		os.Exit(1)
+	}
+	// Only the account filters apply to the transactions; the dates limit the
+	// rows written, since every balance depends on all earlier transactions.
+	filter, err := newTransactionFilter(*from, *to, *accounts, *excludeAccounts, "", "", false, "")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	dateFilter := transactionFilter{From: filter.From, To: filter.To}
+	filter.From, filter.To = time.Time{}, time.Time{}
+
+	var accountMapping map[string]string
+	if *accountMapFile != "" {
+		accountMapping, err = loadMapping(*accountMapFile)
+		if err != nil {
+			fmt.Println("Error loading mapping:", err)
+			return
+		}
+	}
+
+	qif, err := loadQIFFiles(inputFile, accountMapping)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+	filterTransactions(qif, filter)
+
+	dates := balanceDates(qif, *interval)
+	var kept []time.Time
+	for _, date := range dates {
+		if dateFilter.includeTransaction(&qifTransaction{Date: date}) {
+			kept = append(kept, date)
+		}
+	}
+	series := accountBalances(qif, kept)
+
+	err = writeBalancesCSV(*outputFile, qif, accountMapping, kept, series, *dateFormat)
+	if err != nil {
+		fmt.Println("Error writing balances:", err)
+		return
+	}
+	fmt.Println("Balances written:", *outputFile, "dates:", len(kept))
+
+	if *netWorthFile != "" {
+		err = writeNetWorthCSV(*netWorthFile, qif, kept, series, *dateFormat)
+		if err != nil {
+			fmt.Println("Error writing net worth:", err)
+			return
+		}
+		fmt.Println("Net worth written:", *netWorthFile)
+	}
+}
+
+// balanceDates lists every day, or every month end, from the first dated
+// transaction to the last one. The last month end is the month of the last
+// transaction, even when that date is still in the future.
+func balanceDates(qif *qifFile, interval string) []time.Time {
+	var first, last time.Time
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			if t.Date.IsZero() {
+				continue
+			}
+			if first.IsZero() || t.Date.Before(first) {
+				first = t.Date
+			}
+			if t.Date.After(last) {
+				last = t.Date
+			}
+		}
+	}
+	var dates []time.Time
+	if first.IsZero() {
+		return dates
+	}
+	if interval == "daily" {
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			dates = append(dates, d)
+		}
+		return dates
+	}
+	month := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !month.After(last) {
+		next := month.AddDate(0, 1, 0)
+		dates = append(dates, next.AddDate(0, 0, -1))
+		month = next
+	}
+	return dates
+}
+
+// accountBalances returns each account's balance at the end of each date.
+// Balances start at zero, so the opening balance transaction Quicken writes
+// at the top of each register is what sets the starting point. An account
+// has no balance (nil) before its first transaction.
+func accountBalances(qif *qifFile, dates []time.Time) map[*qifAccount][]*money {
+	series := make(map[*qifAccount][]*money)
+	for _, account := range qif.Accounts {
+		var transactions []*qifTransaction
+		for _, t := range account.Transactions {
+			if !t.Date.IsZero() {
+				transactions = append(transactions, t)
+			}
+		}
+		if len(transactions) == 0 {
+			continue
+		}
+		sort.SliceStable(transactions, func(i, j int) bool {
+			return transactions[i].Date.Before(transactions[j].Date)
+		})
+
+		balances := make([]*money, len(dates))
+		var balance money
+		next := 0
+		for i, date := range dates {
+			for next < len(transactions) && !transactions[next].Date.After(date) {
+				balance += transactions[next].Amount
+				next++
+			}
+			if next > 0 {
+				value := balance
+				balances[i] = &value
+			}
+		}
+		series[account] = balances
+	}
+	return series
+}
+
+func writeBalancesCSV(outputFileName string, qif *qifFile, accountMapping map[string]string, dates []time.Time, series map[*qifAccount][]*money, dateFormat string) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Date", "Account", "Balance"})
+	for i, date := range dates {
+		for _, account := range qif.Accounts {
+			balances := series[account]
+			if balances == nil || balances[i] == nil {
+				continue
+			}
+			writer.Write([]string{formatDate(date, dateFormat), ofxAccountName(account.Name, accountMapping), balances[i].String()})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// isLiabilityAccount reports whether an account type is money owed.
+func isLiabilityAccount(account *qifAccount) bool {
+	return account.Type == "CCard" || account.Type == "Oth L"
+}
+
+// writeNetWorthCSV sums the asset balances and the liability balances per
+// date. Liability balances are negative in QIF, so Liabilities is written as
+// the amount owed and Net Worth is Assets minus Liabilities.
+func writeNetWorthCSV(outputFileName string, qif *qifFile, dates []time.Time, series map[*qifAccount][]*money, dateFormat string) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Date", "Assets", "Liabilities", "Net Worth"})
+	for i, date := range dates {
+		var assets, liabilities money
+		for _, account := range qif.Accounts {
+			balances := series[account]
+			if balances == nil || balances[i] == nil {
+				continue
+			}
+			if isLiabilityAccount(account) {
+				liabilities -= *balances[i]
+			} else {
+				assets += *balances[i]
+			}
+		}
+		writer.Write([]string{formatDate(date, dateFormat), assets.String(), liabilities.String(), (assets - liabilities).String()})
+	}
+	writer.Flush()
+	return writer.Error()
+}
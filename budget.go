@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runBudget is the budget subcommand: it exports the category budgets as a
+// monthly table and optionally compares them with the year's transactions.
+func runBudget(args []string) {
+	budgetCmd := flag.NewFlagSet("budget", flag.ExitOnError)
+	var inputFile inputFileList
+	budgetCmd.Var(&inputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
+	year := budgetCmd.Int("year", time.Now().Year(), "budget year")
+	outputFile := budgetCmd.String("outputfile", "budget.csv", "Month,Category,Amount budget table")
+	actualFile := budgetCmd.String("actualfile", "", "also write a budget vs actual report to this file")
+	categoryMapFile := budgetCmd.String("categorymap", "", "category mapping file")
+	budgetCmd.Parse(args)
+	fmt.Println("subcommand 'budget'")
+	fmt.Println("	inputfile:", inputFile.String())
+	fmt.Println("	year:", *year)
+	fmt.Println("	outputfile:", *outputFile)
+	fmt.Println("	actualfile:", *actualFile)
+	fmt.Println("	categorymap:", *categoryMapFile)
+
+	var categoryMapping map[string]string
+	var err error
+	if *categoryMapFile != "" {
+		categoryMapping, err = loadMapping(*categoryMapFile)
+		if err != nil {
+			fmt.Println("Error loading mapping:", err)
+			return
+		}
+	}
+
+	qif, err := loadQIFFiles(inputFile, nil)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+	applyTransactionMappings(qif, nil, categoryMapping, nil)
+
+	budgets := categoryBudgets(qif)
+	if len(budgets) == 0 {
+		fmt.Println("No category budgets found.")
+	}
+	err = writeBudgetCSV(*outputFile, budgets, *year)
+	if err != nil {
+		fmt.Println("Error writing budget:", err)
+		return
+	}
+	fmt.Println("Budget written:", *outputFile, "categories:", len(budgets))
+
+	if *actualFile != "" {
+		actuals := budgetActuals(qif, budgets, *year)
+		err = writeBudgetVsActual(*actualFile, budgets, actuals, *year)
+		if err != nil {
+			fmt.Println("Error writing budget vs actual:", err)
+			return
+		}
+		fmt.Println("Budget vs actual written:", *actualFile)
+	}
+}
+
+// categoryBudgets returns the monthly budget of every category with B lines.
+// Categories that map to the same name are added together.
+func categoryBudgets(qif *qifFile) map[string][12]money {
+	budgets := make(map[string][12]money)
+	for _, category := range qif.Categories {
+		if len(category.Budget) == 0 {
+			continue
+		}
+		months := budgets[category.Name]
+		for i, amount := range category.monthlyBudget() {
+			months[i] += amount
+		}
+		budgets[category.Name] = months
+	}
+	return budgets
+}
+
+func sortedBudgetCategories(budgets map[string][12]money) []string {
+	var names []string
+	for name := range budgets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// budgetCategory finds the budgeted category a transaction counts towards:
+// its own, or else the nearest budgeted parent.
+func budgetCategory(name string, budgets map[string][12]money) (string, bool) {
+	for {
+		if _, ok := budgets[name]; ok {
+			return name, true
+		}
+		colon := strings.LastIndex(name, ":")
+		if colon < 0 {
+			return "", false
+		}
+		name = name[:colon]
+	}
+}
+
+// budgetActuals totals the year's transactions and splits per budgeted
+// category and month.
+func budgetActuals(qif *qifFile, budgets map[string][12]money, year int) map[string][12]money {
+	actuals := make(map[string][12]money)
+	add := func(value string, amount money, date time.Time) {
+		name, _ := splitCategoryAndTag(value)
+		category, ok := budgetCategory(name, budgets)
+		if !ok {
+			return
+		}
+		months := actuals[category]
+		months[date.Month()-1] += amount
+		actuals[category] = months
+	}
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			if t.Date.Year() != year {
+				continue
+			}
+			if len(t.Splits) == 0 {
+				add(t.Category, t.Amount, t.Date)
+			}
+			for _, split := range t.Splits {
+				add(split.Category, split.Amount, t.Date)
+			}
+		}
+	}
+	return actuals
+}
+
+// writeBudgetCSV writes one row per month and category, the long layout
+// budget imports take, with months as YYYY-MM.
+func writeBudgetCSV(outputFileName string, budgets map[string][12]money, year int) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Month", "Category", "Amount"})
+	for month := 0; month < 12; month++ {
+		for _, name := range sortedBudgetCategories(budgets) {
+			writer.Write([]string{fmt.Sprintf("%d-%02d", year, month+1), name, budgets[name][month].String()})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeBudgetVsActual writes budget, actual and difference per category and
+// month, then a year row per category. Difference is actual minus budget,
+// so with expenses negative it is negative when spending went over budget.
+func writeBudgetVsActual(outputFileName string, budgets map[string][12]money, actuals map[string][12]money, year int) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Category", "Month", "Budget", "Actual", "Difference"})
+	for _, name := range sortedBudgetCategories(budgets) {
+		var budgetTotal, actualTotal money
+		for month := 0; month < 12; month++ {
+			budget := budgets[name][month]
+			actual := actuals[name][month]
+			budgetTotal += budget
+			actualTotal += actual
+			writer.Write([]string{name, fmt.Sprintf("%d-%02d", year, month+1), budget.String(), actual.String(), (actual - budget).String()})
+		}
+		writer.Write([]string{name, fmt.Sprint(year), budgetTotal.String(), actualTotal.String(), (actualTotal - budgetTotal).String()})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// categoryModel is a naive Bayes classifier over payee words, memo words
+// and an amount bucket, trained on the categorized transactions of a file.
+type categoryModel struct {
+	Counts     map[string]int
+	Features   map[string]map[string]int
+	Totals     map[string]int
+	Vocabulary map[string]bool
+	Documents  int
+}
+
+// categorySuggestion is the classifier's best guess for one transaction.
+type categorySuggestion struct {
+	Account     string
+	Transaction *qifTransaction
+	Category    string
+	Confidence  float64
+}
+
+// runSuggestCategories is the suggest-categories subcommand: it writes a
+// suggested category for each uncategorized transaction.
+func runSuggestCategories(args []string) {
+	suggestCmd := flag.NewFlagSet("suggest-categories", flag.ExitOnError)
+	var inputFile inputFileList
+	suggestCmd.Var(&inputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
+	outputFile := suggestCmd.String("outputfile", "categorySuggestions.csv", "suggestions CSV")
+	minConfidence := suggestCmd.Float64("min-confidence", 0, "only list suggestions at or above this confidence (0-1)")
+	suggestCmd.Parse(args)
+	fmt.Println("subcommand 'suggest-categories'")
+	fmt.Println("	inputfile:", inputFile.String())
+	fmt.Println("	outputfile:", *outputFile)
+	fmt.Println("	min-confidence:", *minConfidence)
+
+	qif, err := loadQIFFiles(inputFile, nil)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+
+	model := trainCategoryModel(qif)
+	fmt.Println("Categorized transactions used for training:", model.Documents)
+	var suggestions []categorySuggestion
+	for _, suggestion := range suggestCategories(qif, model) {
+		if suggestion.Confidence >= *minConfidence {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+
+	err = writeCategorySuggestions(*outputFile, suggestions)
+	if err != nil {
+		fmt.Println("Error writing suggestions:", err)
+		return
+	}
+	fmt.Println("Suggestions written:", *outputFile, "transactions:", len(suggestions))
+}
+
+// isUncategorized reports whether a transaction has no category to learn
+// from: an empty L line or Quicken's "Uncategorized". Split transactions and
+// transfers have their own categories and are never treated as missing one.
+func isUncategorized(t *qifTransaction) bool {
+	if len(t.Splits) > 0 {
+		return false
+	}
+	category, _ := splitCategoryAndTag(t.Category)
+	return category == "" || strings.EqualFold(category, uncategorizedName)
+}
+
+// categoryFeatures are the words of the cleaned payee, the memo words and
+// the amount's sign and order of magnitude. The payee is the one read from
+// the file, so a payee mapping applied after training does not change them.
+func categoryFeatures(t *qifTransaction) []string {
+	payee := t.OriginalPayee
+	if payee == "" {
+		payee = t.Payee
+	}
+	var features []string
+	for _, word := range cleanPayee(payee) {
+		features = append(features, "p:"+word)
+	}
+	for _, word := range strings.Fields(strings.ToUpper(t.Memo)) {
+		word = strings.Trim(word, ".,;:!?()\"'")
+		if len(word) > 1 && strings.IndexFunc(word, func(r rune) bool { return r >= '0' && r <= '9' }) < 0 {
+			features = append(features, "m:"+word)
+		}
+	}
+	sign := "+"
+	if t.Amount < 0 {
+		sign = "-"
+	}
+	magnitude := 0
+	if t.Amount.Abs() >= 100 {
+		magnitude = int(math.Log10(float64(t.Amount.Abs()) / 100))
+	}
+	return append(features, fmt.Sprintf("a:%s%d", sign, magnitude))
+}
+
+// trainCategoryModel learns from every categorized transaction that is not
+// split or a transfer.
+func trainCategoryModel(qif *qifFile) *categoryModel {
+	model := &categoryModel{
+		Counts:     make(map[string]int),
+		Features:   make(map[string]map[string]int),
+		Totals:     make(map[string]int),
+		Vocabulary: make(map[string]bool),
+	}
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			// isUncategorized does not skip split transactions, whose own
+			// category is empty; their categories are on the splits
+			category, _ := splitCategoryAndTag(t.Category)
+			if len(t.Splits) > 0 || isUncategorized(t) || strings.HasPrefix(category, "[") {
+				continue
+			}
+			model.Documents++
+			model.Counts[category]++
+			if model.Features[category] == nil {
+				model.Features[category] = make(map[string]int)
+			}
+			for _, feature := range categoryFeatures(t) {
+				model.Features[category][feature]++
+				model.Totals[category]++
+				model.Vocabulary[feature] = true
+			}
+		}
+	}
+	return model
+}
+
+// predict returns the most likely category and its probability among all
+// categories, with add-one smoothing.
+func (m *categoryModel) predict(t *qifTransaction) (string, float64) {
+	if m.Documents == 0 {
+		return "", 0
+	}
+	features := categoryFeatures(t)
+	var categories []string
+	for category := range m.Counts {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	scores := make([]float64, len(categories))
+	best := 0
+	for i, category := range categories {
+		score := math.Log(float64(m.Counts[category]) / float64(m.Documents))
+		denominator := float64(m.Totals[category] + len(m.Vocabulary))
+		for _, feature := range features {
+			score += math.Log(float64(m.Features[category][feature]+1) / denominator)
+		}
+		scores[i] = score
+		if score > scores[best] {
+			best = i
+		}
+	}
+
+	var sum float64
+	for _, score := range scores {
+		sum += math.Exp(score - scores[best])
+	}
+	return categories[best], 1 / sum
+}
+
+// suggestCategories predicts a category for each uncategorized transaction.
+func suggestCategories(qif *qifFile, model *categoryModel) []categorySuggestion {
+	var suggestions []categorySuggestion
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			if !isUncategorized(t) {
+				continue
+			}
+			category, confidence := model.predict(t)
+			if category == "" {
+				continue
+			}
+			suggestions = append(suggestions, categorySuggestion{Account: account.Name, Transaction: t, Category: category, Confidence: confidence})
+		}
+	}
+	return suggestions
+}
+
+// applyCategorySuggestions fills in the suggested category, keeping any
+// tag, wherever the confidence reaches threshold, and returns how many
+// transactions were changed. The model is trained on the file's own
+// categories, so suggestions are renamed through categoryMapping like the
+// rest.
+func applyCategorySuggestions(qif *qifFile, model *categoryModel, categoryMapping map[string]string, threshold float64) int {
+	applied := 0
+	for _, suggestion := range suggestCategories(qif, model) {
+		if suggestion.Confidence < threshold {
+			continue
+		}
+		category := suggestion.Category
+		if len(categoryMapping) > 0 {
+			category = applyMapping(category, categoryMapping)
+		}
+		_, tag := splitCategoryAndTag(suggestion.Transaction.Category)
+		suggestion.Transaction.Category = joinCategoryAndTag(category, tag)
+		applied++
+	}
+	return applied
+}
+
+func writeCategorySuggestions(outputFileName string, suggestions []categorySuggestion) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Account", "Date", "Payee", "Memo", "Amount", "Suggested Category", "Confidence"})
+	for _, s := range suggestions {
+		writer.Write([]string{
+			s.Account,
+			s.Transaction.Date.Format("2006-01-02"),
+			s.Transaction.Payee,
+			s.Transaction.Memo,
+			s.Transaction.Amount.String(),
+			s.Category,
+			fmt.Sprintf("%.2f", s.Confidence),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const suggestionFixture = `!Account
+NChecking
+TBank
+^
+!Type:Bank
+D3/ 1'24
+T-5.00
+PCorner Cafe
+LDining
+^
+D3/ 2'24
+T-6.00
+PCorner Cafe
+LDining
+^
+D3/ 3'24
+T-80.00
+PCity Power
+LUtilities
+^
+D3/ 4'24
+T-82.00
+PCity Power
+LUtilities
+^
+D3/ 5'24
+T-100.00
+PMarket
+SGroceries
+$-100.00
+^
+D3/ 6'24
+T-200.00
+PBank Transfer
+L[Savings]
+^
+D3/ 7'24
+T-5.50
+PCorner Cafe
+L/Vacation
+^
+D3/ 8'24
+T-81.00
+PCity Power
+^
+D3/ 9'24
+T-7.00
+PUnknown Shop
+^
+`
+
+func TestTrainCategoryModel(t *testing.T) {
+	model := trainCategoryModel(parseQIF(suggestionFixture))
+	// Split, transfer and uncategorized transactions are not learned from
+	if model.Documents != 4 {
+		t.Errorf("trained on %d transactions, want 4", model.Documents)
+	}
+	for category := range model.Counts {
+		if category != "Dining" && category != "Utilities" {
+			t.Errorf("learned category %q", category)
+		}
+	}
+}
+
+func TestPredictCategory(t *testing.T) {
+	qif := parseQIF(suggestionFixture)
+	model := trainCategoryModel(qif)
+	transactions := qif.Accounts[0].Transactions
+	tests := []struct {
+		transaction *qifTransaction
+		want        string
+	}{
+		{transactions[6], "Dining"},
+		{transactions[7], "Utilities"},
+	}
+	for _, test := range tests {
+		category, confidence := model.predict(test.transaction)
+		if category != test.want {
+			t.Errorf("predict(%s) = %q, want %q", test.transaction.Payee, category, test.want)
+		}
+		if confidence <= 0.5 || confidence > 1 {
+			t.Errorf("predict(%s) confidence %v, want between 0.5 and 1", test.transaction.Payee, confidence)
+		}
+	}
+	if category, _ := (&categoryModel{}).predict(transactions[6]); category != "" {
+		t.Errorf("empty model predicted %q", category)
+	}
+}
+
+func TestApplyCategorySuggestions(t *testing.T) {
+	qif := parseQIF(suggestionFixture)
+	model := trainCategoryModel(qif)
+	applied := applyCategorySuggestions(qif, model, map[string]string{"Dining": "Food:Restaurants"}, 0.9)
+
+	transactions := qif.Accounts[0].Transactions
+	// The suggestion is renamed through the category mapping and the tag is
+	// kept
+	if got := transactions[6].Category; got != "Food:Restaurants/Vacation" {
+		t.Errorf("cafe category %q, want Food:Restaurants/Vacation", got)
+	}
+	if got := transactions[7].Category; got != "Utilities" {
+		t.Errorf("power category %q, want Utilities", got)
+	}
+	// Low confidence guesses are left alone
+	if got := transactions[8].Category; got != "" {
+		t.Errorf("unknown shop category %q, want it left empty", got)
+	}
+	if applied != 2 {
+		t.Errorf("applied %d suggestions, want 2", applied)
+	}
+}
+
+// TestCategorySuggestionsAfterPayeeRules follows convert: the model learns
+// from the file as read, the payee mapping and rules run, and suggestions
+// only fill what the rules left uncategorized.
+func TestCategorySuggestionsAfterPayeeRules(t *testing.T) {
+	qif := parseQIF(suggestionFixture)
+	model := trainCategoryModel(qif)
+	payeeMapping := map[string]string{"Corner Cafe": "Cafe"}
+	payeeRules := map[string]string{"City Power": "Bills:Electric"}
+	applyTransactionMappings(qif, payeeMapping, nil, payeeRules)
+	applyCategorySuggestions(qif, model, nil, 0.9)
+
+	transactions := qif.Accounts[0].Transactions
+	if got := transactions[7].Category; got != "Bills:Electric" {
+		t.Errorf("power category %q, want the payee rule's Bills:Electric", got)
+	}
+	// The mapped payee does not stop the cafe from being recognised
+	if got := transactions[6].Payee + " " + transactions[6].Category; got != "Cafe Dining/Vacation" {
+		t.Errorf("cafe is %q, want Cafe Dining/Vacation", got)
+	}
+	if !strings.EqualFold(transactions[6].OriginalPayee, "Corner Cafe") {
+		t.Errorf("original payee %q", transactions[6].OriginalPayee)
+	}
+}
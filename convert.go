@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// convertOptions carries the convert subcommand's flags to exportTransactions.
+type convertOptions struct {
+	InputFileNames       []string
+	OutputFileName       string
+	CategoryMappingFile  string
+	PayeeMappingFile     string
+	AccountMappingFile   string
+	PayeeRulesFile       string
+	InvertSigns          []string
+	AmountStyle          string
+	DateFormat           string
+	Filter               transactionFilter
+	StateFile            string
+	StateGraceDays       int
+	Dedupe               string
+	DedupeDays           int
+	DedupeAcrossAccounts bool
+	DedupeReviewFile     string
+	Format               string
+	LedgerMappingFile    string
+	BankID               string
+	SuggestCategories    float64
+}
+
+// outputFormats are the values accepted by convert's -format flag.
+var outputFormats = []string{"csv", "qif", "ofx", "ofx1", "ledger", "beancount", "json", "ndjson", "sqlite", "xlsx"}
+
+func checkOutputFormat(format string) error {
+	for _, known := range outputFormats {
+		if format == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format: %s (expected %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// applyTransactionMappings rewrites payees and categories in place so every
+// output format sees the mapped values. The tag or class after "/" in a
+// category is kept as is.
+func applyTransactionMappings(qif *qifFile, payeeMapping map[string]string, categoryMapping map[string]string, payeeRules map[string]string) {
+	mapCategory := func(value string) string {
+		category, tag := splitCategoryAndTag(value)
+		if len(categoryMapping) > 0 {
+			category = applyMapping(category, categoryMapping)
+		}
+		return joinCategoryAndTag(category, tag)
+	}
+
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			if len(payeeMapping) > 0 {
+				t.Payee = applyMapping(t.Payee, payeeMapping)
+			}
+			if len(payeeRules) > 0 {
+				category, tag := splitCategoryAndTag(t.Category)
+				if len(t.Splits) == 0 {
+					category = applyPayeeRules(prepareString(t.Payee), category, payeeRules)
+				}
+				t.Category = joinCategoryAndTag(category, tag)
+			}
+			t.Category = mapCategory(t.Category)
+			for _, split := range t.Splits {
+				split.Category = mapCategory(split.Category)
+			}
+		}
+	}
+
+	if len(categoryMapping) > 0 {
+		for _, category := range qif.Categories {
+			category.Name = applyMapping(category.Name, categoryMapping)
+		}
+	}
+}
+
+// joinCategoryAndTag is the inverse of splitCategoryAndTag.
+func joinCategoryAndTag(category string, tag string) string {
+	if tag == "" {
+		return category
+	}
+	return category + "/" + tag
+}
+
+// datePresets are the named values accepted by -dateformat. Anything else is
+// used as a Go time layout.
+var datePresets = map[string]string{
+	"iso": "2006-01-02",
+	"us":  "01/02/2006",
+	"eu":  "02.01.2006",
+}
+
+// formatDate writes a transaction date in the -dateformat layout. The "excel"
+// preset writes the spreadsheet serial day number, counted from 1899-12-30.
+// A missing (zero) date is written as an empty value in every format.
+func formatDate(date time.Time, format string) string {
+	if date.IsZero() {
+		return ""
+	}
+	format = strings.TrimSpace(format)
+	if format == "" {
+		format = "iso"
+	}
+	if strings.EqualFold(format, "excel") {
+		day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+		excelEpoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		return strconv.FormatInt((day.Unix()-excelEpoch.Unix())/86400, 10)
+	}
+	if layout, ok := datePresets[strings.ToLower(format)]; ok {
+		format = layout
+	}
+	return date.Format(format)
+}
+
+// checkAmountStyle validates the -amountstyle flag.
+func checkAmountStyle(style string) error {
+	switch style {
+	case "signed", "inflowoutflow", "debitcredit":
+		return nil
+	}
+	return fmt.Errorf("unknown amount style: %s (expected signed, inflowoutflow or debitcredit)", style)
+}
+
+// amountHeader returns the CSV header columns for an amount style.
+func amountHeader(style string) string {
+	switch style {
+	case "inflowoutflow":
+		return "Inflow,Outflow"
+	case "debitcredit":
+		return "Amount,Type"
+	}
+	return "Amount"
+}
+
+// amountColumns formats a parsed amount for an amount style. Inflow/Outflow
+// and Debit/Credit both write positive numbers and carry the direction in the
+// column or the indicator.
+func amountColumns(amount money, style string) string {
+	switch style {
+	case "inflowoutflow":
+		if amount < 0 {
+			return "," + amount.Abs().String()
+		}
+		return amount.String() + ","
+	case "debitcredit":
+		if amount < 0 {
+			return amount.Abs().String() + ",Debit"
+		}
+		return amount.String() + ",Credit"
+	}
+	return amount.String()
+}
+
+// matchesAccount reports whether an account is named by one of the patterns,
+// either by account name, by QIF account type or by a glob on the name.
+func matchesAccount(account *qifAccount, patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.EqualFold(pattern, account.Name) || strings.EqualFold(pattern, account.Type) {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, account.Name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated flag value, dropping blank entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// inputFileList collects repeated -inputfile flags. Each value may be a glob.
+type inputFileList []string
+
+func (l *inputFileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *inputFileList) Set(value string) error {
+	matches, err := filepath.Glob(value)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		// Keep the name so the missing file is reported when it is read
+		matches = []string{value}
+	}
+	*l = append(*l, matches...)
+	return nil
+}
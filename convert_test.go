@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"", "2024-03-05"},
+		{"iso", "2024-03-05"},
+		{"ISO", "2024-03-05"},
+		{"us", "03/05/2024"},
+		{"eu", "05.03.2024"},
+		{"excel", "45356"},
+		{"2006/01/02", "2024/03/05"},
+	}
+	for _, test := range tests {
+		if got := formatDate(date, test.format); got != test.want {
+			t.Errorf("formatDate(%s, %q) = %q, want %q", date.Format("2006-01-02"), test.format, got, test.want)
+		}
+	}
+}
+
+func TestFormatDateExcel(t *testing.T) {
+	tests := []struct {
+		date time.Time
+		want string
+	}{
+		{time.Date(1899, time.December, 31, 0, 0, 0, 0, time.UTC), "1"},
+		{time.Date(1900, time.March, 1, 0, 0, 0, 0, time.UTC), "61"},
+		{time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC), "36526"},
+		// The time of day does not move the serial
+		{time.Date(2000, time.January, 1, 23, 59, 0, 0, time.UTC), "36526"},
+	}
+	for _, test := range tests {
+		if got := formatDate(test.date, "excel"); got != test.want {
+			t.Errorf("formatDate(%s, excel) = %q, want %q", test.date, got, test.want)
+		}
+	}
+}
+
+func TestFormatDateZero(t *testing.T) {
+	for _, format := range []string{"iso", "us", "eu", "excel", "2006/01/02"} {
+		if got := formatDate(time.Time{}, format); got != "" {
+			t.Errorf("formatDate(zero, %q) = %q, want empty", format, got)
+		}
+	}
+}
+
+func TestAmountColumns(t *testing.T) {
+	tests := []struct {
+		style  string
+		amount money
+		header string
+		want   string
+	}{
+		{"signed", -4520, "Amount", "-45.20"},
+		{"signed", 4520, "Amount", "45.20"},
+		{"inflowoutflow", -4520, "Inflow,Outflow", ",45.20"},
+		{"inflowoutflow", 4520, "Inflow,Outflow", "45.20,"},
+		{"inflowoutflow", 0, "Inflow,Outflow", "0.00,"},
+		{"debitcredit", -4520, "Amount,Type", "45.20,Debit"},
+		{"debitcredit", 4520, "Amount,Type", "45.20,Credit"},
+	}
+	for _, test := range tests {
+		if err := checkAmountStyle(test.style); err != nil {
+			t.Errorf("checkAmountStyle(%q): %v", test.style, err)
+		}
+		if got := amountHeader(test.style); got != test.header {
+			t.Errorf("amountHeader(%q) = %q, want %q", test.style, got, test.header)
+		}
+		if got := amountColumns(test.amount, test.style); got != test.want {
+			t.Errorf("amountColumns(%s, %q) = %q, want %q", test.amount, test.style, got, test.want)
+		}
+	}
+	if err := checkAmountStyle("plusminus"); err == nil {
+		t.Error("checkAmountStyle accepted an unknown style")
+	}
+}
+
+func TestMatchesAccount(t *testing.T) {
+	visa := &qifAccount{Name: "Visa Rewards", Type: "CCard"}
+	tests := []struct {
+		patterns []string
+		want     bool
+	}{
+		{nil, false},
+		{[]string{"ccard"}, true},
+		{[]string{"visa rewards"}, true},
+		{[]string{"Visa*"}, true},
+		{[]string{"Checking", "Bank"}, false},
+	}
+	for _, test := range tests {
+		if got := matchesAccount(visa, test.patterns); got != test.want {
+			t.Errorf("matchesAccount(%v) = %v, want %v", test.patterns, got, test.want)
+		}
+	}
+}
+
+// TestWriteCSVFilesInvertSigns checks that -invertsigns flips only the named
+// accounts, before the amount style is applied.
+func TestWriteCSVFilesInvertSigns(t *testing.T) {
+	qif := parseQIF(`!Account
+NChecking
+TBank
+^
+!Type:Bank
+D3/ 5'24
+T-45.20
+PGrocer
+LGroceries
+^
+!Account
+NVisa
+TCCard
+^
+!Type:CCard
+D3/ 6'24
+T-12.00
+PCafe
+LDining
+^
+`)
+	dir := t.TempDir()
+	options := convertOptions{
+		OutputFileName: ".csv",
+		InvertSigns:    []string{"CCard"},
+		AmountStyle:    "debitcredit",
+		DateFormat:     "us",
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := writeCSVFiles(qif, nil, options, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"Checking.csv": "03/05/2024,Grocer,Groceries,Checking,Grocer,,45.20,Debit,",
+		"Visa.csv":     "03/06/2024,Cafe,Dining,Visa,Cafe,,12.00,Credit,",
+	}
+	for name, row := range want {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		if lines[0] != "Date,Merchant,Category,Account,Original Statement,Notes,Amount,Type,Tags" {
+			t.Errorf("%s: header %q", name, lines[0])
+		}
+		if len(lines) != 2 || lines[1] != row {
+			t.Errorf("%s: rows %q, want %q", name, lines[1:], row)
+		}
+	}
+}
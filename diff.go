@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// diffEntry is one difference between two exports.
+type diffEntry struct {
+	Kind    string
+	Change  string
+	Account string
+	Date    string
+	Payee   string
+	Amount  string
+	Number  string
+	Details string
+}
+
+// runDiff is the diff subcommand: it compares an old and a new export.
+func runDiff(args []string) {
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+	oldFile := diffCmd.String("old", "", "previous QIF export")
+	newFile := diffCmd.String("new", "", "current QIF export")
+	format := diffCmd.String("format", "text", "output format: text or csv")
+	outputFile := diffCmd.String("outputfile", "", "output file (default stdout, required for csv)")
+	diffCmd.Parse(args)
+	fmt.Println("subcommand 'diff'")
+	fmt.Println("	old:", *oldFile)
+	fmt.Println("	new:", *newFile)
+	fmt.Println("	format:", *format)
+	fmt.Println("	outputfile:", *outputFile)
+
+	if *format != "text" && *format != "csv" {
+		fmt.Println("unknown diff format:", *format, "(expected text or csv)")
+		os.Exit(1)
+	}
+	// The progress lines go to stdout as well, so CSV needs a file of its own
+	if *format == "csv" && *outputFile == "" {
+		fmt.Println("-outputfile is required with -format csv")
+		os.Exit(1)
+	}
+
+	oldQIF, err := loadQIFFile(*oldFile)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+	newQIF, err := loadQIFFile(*newFile)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+
+	entries := diffTransactions(oldQIF, newQIF)
+	entries = append(entries, diffNames("category", qifCategoryNames(oldQIF), qifCategoryNames(newQIF))...)
+	entries = append(entries, diffNames("payee", qifPayeeNames(oldQIF), qifPayeeNames(newQIF))...)
+	entries = append(entries, diffNames("tag", qifTagNames(oldQIF), qifTagNames(newQIF))...)
+
+	var out io.Writer = os.Stdout
+	if *outputFile != "" {
+		file, err := os.Create(*outputFile)
+		if err != nil {
+			fmt.Println("Error creating file:", err)
+			return
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if *format == "csv" {
+		err = writeDiffCSV(out, entries)
+	} else {
+		err = writeDiffText(out, entries)
+	}
+	if err != nil {
+		fmt.Println("Error writing diff:", err)
+		return
+	}
+	fmt.Println("Differences: ", len(entries))
+}
+
+// transactionKey identifies a transaction for matching between exports.
+func transactionKey(t *qifTransaction) string {
+	return t.Date.Format("2006-01-02") + "|" + t.Amount.String() + "|" + normalizePayee(t.Payee) + "|" + t.Number
+}
+
+// diffTransactions compares the accounts of two exports. Transactions are
+// first matched on date, amount, payee and check number; the leftovers are
+// paired on date and amount, or on check number and amount, and reported as
+// modified. Anything still unmatched was added or removed.
+func diffTransactions(oldQIF *qifFile, newQIF *qifFile) []diffEntry {
+	var entries []diffEntry
+
+	oldAccounts := make(map[string]*qifAccount)
+	for _, account := range oldQIF.Accounts {
+		oldAccounts[account.Name] = account
+	}
+	newAccounts := make(map[string]*qifAccount)
+	var names []string
+	for _, account := range newQIF.Accounts {
+		newAccounts[account.Name] = account
+		names = append(names, account.Name)
+	}
+	for _, account := range oldQIF.Accounts {
+		if newAccounts[account.Name] == nil {
+			names = append(names, account.Name)
+		}
+	}
+
+	for _, name := range names {
+		var oldTransactions, newTransactions []*qifTransaction
+		if account := oldAccounts[name]; account != nil {
+			oldTransactions = account.Transactions
+		}
+		if account := newAccounts[name]; account != nil {
+			newTransactions = account.Transactions
+		}
+
+		// Exact matches
+		unmatchedOld := make(map[string][]*qifTransaction)
+		for _, t := range oldTransactions {
+			key := transactionKey(t)
+			unmatchedOld[key] = append(unmatchedOld[key], t)
+		}
+		var addedCandidates []*qifTransaction
+		for _, t := range newTransactions {
+			key := transactionKey(t)
+			if candidates := unmatchedOld[key]; len(candidates) > 0 {
+				unmatchedOld[key] = candidates[1:]
+				if details := transactionChanges(candidates[0], t); details != "" {
+					entries = append(entries, newDiffEntry("modified", name, t, details))
+				}
+				continue
+			}
+			addedCandidates = append(addedCandidates, t)
+		}
+		var removedCandidates []*qifTransaction
+		for _, t := range oldTransactions {
+			key := transactionKey(t)
+			if candidates := unmatchedOld[key]; len(candidates) > 0 && candidates[0] == t {
+				unmatchedOld[key] = candidates[1:]
+				removedCandidates = append(removedCandidates, t)
+			}
+		}
+
+		// Pair the leftovers that still share a date or check number
+		for _, t := range addedCandidates {
+			match := -1
+			for i, old := range removedCandidates {
+				if old.Amount != t.Amount {
+					continue
+				}
+				if old.Date.Equal(t.Date) || (old.Number != "" && old.Number == t.Number) {
+					match = i
+					break
+				}
+			}
+			if match < 0 {
+				entries = append(entries, newDiffEntry("added", name, t, ""))
+				continue
+			}
+			entries = append(entries, newDiffEntry("modified", name, t, transactionChanges(removedCandidates[match], t)))
+			removedCandidates = append(removedCandidates[:match], removedCandidates[match+1:]...)
+		}
+		for _, t := range removedCandidates {
+			entries = append(entries, newDiffEntry("removed", name, t, ""))
+		}
+	}
+	return entries
+}
+
+func newDiffEntry(change string, account string, t *qifTransaction, details string) diffEntry {
+	return diffEntry{
+		Kind:    "transaction",
+		Change:  change,
+		Account: account,
+		Date:    t.Date.Format("2006-01-02"),
+		Payee:   t.Payee,
+		Amount:  t.Amount.String(),
+		Number:  t.Number,
+		Details: details,
+	}
+}
+
+// transactionChanges lists the fields that differ between two versions of a
+// transaction, e.g. "category: Food -> Groceries".
+func transactionChanges(old *qifTransaction, updated *qifTransaction) string {
+	var changes []string
+	compare := func(field string, a string, b string) {
+		if a != b {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", field, a, b))
+		}
+	}
+	compare("date", old.Date.Format("2006-01-02"), updated.Date.Format("2006-01-02"))
+	compare("payee", old.Payee, updated.Payee)
+	compare("number", old.Number, updated.Number)
+	compare("memo", old.Memo, updated.Memo)
+	compare("category", old.Category, updated.Category)
+	compare("cleared", old.Cleared, updated.Cleared)
+	compare("splits", splitsSummary(old.Splits), splitsSummary(updated.Splits))
+	return strings.Join(changes, "; ")
+}
+
+func splitsSummary(splits []*qifSplit) string {
+	var parts []string
+	for _, split := range splits {
+		parts = append(parts, split.Category+" "+split.Amount.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// diffNames reports names present in only one of the two lists.
+func diffNames(kind string, oldNames []string, newNames []string) []diffEntry {
+	var entries []diffEntry
+	oldSet := make(map[string]bool)
+	for _, name := range oldNames {
+		oldSet[name] = true
+	}
+	newSet := make(map[string]bool)
+	for _, name := range newNames {
+		newSet[name] = true
+		if !oldSet[name] {
+			entries = append(entries, diffEntry{Kind: kind, Change: "added", Details: name})
+		}
+	}
+	for _, name := range oldNames {
+		if !newSet[name] {
+			entries = append(entries, diffEntry{Kind: kind, Change: "removed", Details: name})
+		}
+	}
+	return entries
+}
+
+// qifCategoryNames returns the categories from the category list and from
+// the transactions, sorted and deduplicated.
+func qifCategoryNames(qif *qifFile) []string {
+	var names []string
+	for _, category := range qif.Categories {
+		names = append(names, category.Name)
+	}
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			category, _ := splitCategoryAndTag(t.Category)
+			names = append(names, category)
+			for _, split := range t.Splits {
+				category, _ := splitCategoryAndTag(split.Category)
+				names = append(names, category)
+			}
+		}
+	}
+	return sortAndDedupStrings(names)
+}
+
+func qifPayeeNames(qif *qifFile) []string {
+	var names []string
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			names = append(names, t.Payee)
+		}
+	}
+	return sortAndDedupStrings(names)
+}
+
+func qifTagNames(qif *qifFile) []string {
+	var names []string
+	for _, tag := range qif.Tags {
+		names = append(names, tag.Name)
+	}
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			_, tag := splitCategoryAndTag(t.Category)
+			names = append(names, tag)
+		}
+	}
+	return sortAndDedupStrings(names)
+}
+
+func writeDiffText(out io.Writer, entries []diffEntry) error {
+	sorted := append([]diffEntry(nil), entries...)
+	// Transactions grouped by account, then the name lists
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if (sorted[i].Kind == "transaction") != (sorted[j].Kind == "transaction") {
+			return sorted[i].Kind == "transaction"
+		}
+		return sorted[i].Account < sorted[j].Account
+	})
+
+	account := ""
+	for i, entry := range sorted {
+		if entry.Kind == "transaction" && (i == 0 || entry.Account != account) {
+			account = entry.Account
+			if _, err := fmt.Fprintf(out, "Account: %s\n", account); err != nil {
+				return err
+			}
+		}
+
+		marker := map[string]string{"added": "+", "removed": "-", "modified": "~"}[entry.Change]
+		var line string
+		if entry.Kind == "transaction" {
+			line = fmt.Sprintf("  %s %s %s %s", marker, entry.Date, entry.Amount, entry.Payee)
+			if entry.Number != "" {
+				line += " #" + entry.Number
+			}
+			if entry.Details != "" {
+				line += " (" + entry.Details + ")"
+			}
+		} else {
+			line = fmt.Sprintf("%s %s: %s", marker, entry.Kind, entry.Details)
+		}
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeDiffCSV(out io.Writer, entries []diffEntry) error {
+	writer := csv.NewWriter(out)
+	writer.Write([]string{"Kind", "Change", "Account", "Date", "Payee", "Amount", "Number", "Details"})
+	for _, entry := range entries {
+		writer.Write([]string{entry.Kind, entry.Change, entry.Account, entry.Date, entry.Payee, entry.Amount, entry.Number, entry.Details})
+	}
+	writer.Flush()
+	return writer.Error()
+}
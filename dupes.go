@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// duplicateMember is one transaction of a duplicate cluster.
+type duplicateMember struct {
+	Account     *qifAccount
+	Transaction *qifTransaction
+}
+
+// duplicateCluster is a group of transactions with the same amount and payee
+// whose dates are close together. Members that also share the account and
+// date are exact duplicates; the rest are fuzzy matches that differ by a few
+// days or sit in different accounts.
+type duplicateCluster struct {
+	Members []duplicateMember
+}
+
+// runDupes is the dupes subcommand: it writes a review CSV listing each
+// duplicate cluster.
+func runDupes(args []string) {
+	dupesCmd := flag.NewFlagSet("dupes", flag.ExitOnError)
+	var inputFile inputFileList
+	dupesCmd.Var(&inputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
+	outputFile := dupesCmd.String("outputfile", "duplicates.csv", "review CSV of duplicate clusters")
+	days := dupesCmd.Int("days", 1, "days apart that still count as a fuzzy duplicate")
+	acrossAccounts := dupesCmd.Bool("across-accounts", true, "also match transactions in different accounts")
+	dupesCmd.Parse(args)
+	fmt.Println("subcommand 'dupes'")
+	fmt.Println("	inputfile:", inputFile.String())
+	fmt.Println("	outputfile:", *outputFile)
+	fmt.Println("	days:", *days)
+	fmt.Println("	across-accounts:", *acrossAccounts)
+
+	qif, err := loadQIFFiles(inputFile, nil)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+
+	clusters := findDuplicates(qif, *days, *acrossAccounts)
+	err = writeDuplicateReview(clusters, *outputFile)
+	if err != nil {
+		fmt.Println("Error writing duplicate review:", err)
+		return
+	}
+
+	exact := 0
+	for _, cluster := range clusters {
+		if len(cluster.exactGroups()) > 0 {
+			exact++
+		}
+	}
+	fmt.Printf("Duplicate clusters: %d (%d with exact duplicates)\n", len(clusters), exact)
+}
+
+// normalizePayee reduces a payee to lower case letters and digits so small
+// differences in punctuation and spacing between downloads still match.
+func normalizePayee(payee string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(payee) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// findDuplicates groups transactions by amount and normalized payee, then
+// clusters those whose dates are within days of the cluster's first member.
+// Measuring from the first member rather than the previous one keeps a run
+// of repeated purchases, like a daily coffee, from chaining into one cluster.
+func findDuplicates(qif *qifFile, days int, acrossAccounts bool) []duplicateCluster {
+	groups := make(map[string][]duplicateMember)
+	var keys []string
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			key := t.Amount.String() + "|" + normalizePayee(t.Payee)
+			if !acrossAccounts {
+				key = account.Name + "|" + key
+			}
+			if _, ok := groups[key]; !ok {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], duplicateMember{Account: account, Transaction: t})
+		}
+	}
+
+	var clusters []duplicateCluster
+	for _, key := range keys {
+		members := groups[key]
+		if len(members) < 2 {
+			continue
+		}
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].Transaction.Date.Before(members[j].Transaction.Date)
+		})
+
+		current := []duplicateMember{members[0]}
+		for _, member := range members[1:] {
+			first := current[0].Transaction.Date
+			if member.Transaction.Date.Sub(first).Hours()/24 <= float64(days) {
+				current = append(current, member)
+				continue
+			}
+			clusters = appendCluster(clusters, current)
+			current = []duplicateMember{member}
+		}
+		clusters = appendCluster(clusters, current)
+	}
+
+	sort.SliceStable(clusters, func(i, j int) bool {
+		return clusters[i].Members[0].Transaction.Date.Before(clusters[j].Members[0].Transaction.Date)
+	})
+	return clusters
+}
+
+func appendCluster(clusters []duplicateCluster, members []duplicateMember) []duplicateCluster {
+	if len(members) < 2 {
+		return clusters
+	}
+	return append(clusters, duplicateCluster{Members: members})
+}
+
+// exactGroups returns the indexes of members sharing an account and date,
+// for each such group of two or more.
+func (c duplicateCluster) exactGroups() [][]int {
+	groups := make(map[string][]int)
+	var keys []string
+	for i, member := range c.Members {
+		key := member.Account.Name + "|" + member.Transaction.Date.Format("2006-01-02")
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	var exact [][]int
+	for _, key := range keys {
+		if len(groups[key]) > 1 {
+			exact = append(exact, groups[key])
+		}
+	}
+	return exact
+}
+
+// keeper picks the member to keep out of indexes: the first cleared or
+// reconciled one, or the earliest if none are.
+func (c duplicateCluster) keeper(indexes []int) int {
+	for _, i := range indexes {
+		if isCleared(c.Members[i].Transaction.Cleared) {
+			return i
+		}
+	}
+	return indexes[0]
+}
+
+// allMembers returns the indexes of every member of the cluster.
+func (c duplicateCluster) allMembers() []int {
+	indexes := make([]int, len(c.Members))
+	for i := range c.Members {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// removeDuplicates drops all but the kept member of each cluster. With
+// exactOnly set, only exact duplicates are removed and fuzzy matches are left
+// alone. It returns the number of transactions removed.
+func removeDuplicates(qif *qifFile, clusters []duplicateCluster, exactOnly bool) int {
+	remove := make(map[*qifTransaction]bool)
+	for _, cluster := range clusters {
+		groups := [][]int{cluster.allMembers()}
+		if exactOnly {
+			groups = cluster.exactGroups()
+		}
+		for _, group := range groups {
+			keep := cluster.keeper(group)
+			for _, i := range group {
+				if i != keep {
+					remove[cluster.Members[i].Transaction] = true
+				}
+			}
+		}
+	}
+
+	for _, account := range qif.Accounts {
+		var kept []*qifTransaction
+		for _, t := range account.Transactions {
+			if !remove[t] {
+				kept = append(kept, t)
+			}
+		}
+		account.Transactions = kept
+	}
+	return len(remove)
+}
+
+func writeDuplicateReview(clusters []duplicateCluster, outputFileName string) error {
+	reviewFile, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer reviewFile.Close()
+
+	writer := csv.NewWriter(reviewFile)
+	writer.Write([]string{"Cluster", "Match", "Keep", "Account", "Date", "Payee", "Amount", "Number", "Cleared", "Memo", "Category", "Source"})
+	for i, cluster := range clusters {
+		exact := make(map[int]bool)
+		for _, group := range cluster.exactGroups() {
+			for _, j := range group {
+				exact[j] = true
+			}
+		}
+		keep := cluster.keeper(cluster.allMembers())
+		for j, member := range cluster.Members {
+			t := member.Transaction
+			match := "fuzzy"
+			if exact[j] {
+				match = "exact"
+			}
+			keepValue := "no"
+			if j == keep {
+				keepValue = "yes"
+			}
+			writer.Write([]string{
+				strconv.Itoa(i + 1),
+				match,
+				keepValue,
+				member.Account.Name,
+				t.Date.Format("2006-01-02"),
+				t.Payee,
+				t.Amount.String(),
+				t.Number,
+				t.Cleared,
+				t.Memo,
+				t.Category,
+				t.Source,
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
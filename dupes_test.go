@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// dailyPurchases is a register with the same purchase on ten days in a row.
+func dailyPurchases() string {
+	var b strings.Builder
+	b.WriteString("!Account\nNChecking\nTBank\n^\n!Type:Bank\n")
+	for day := 1; day <= 10; day++ {
+		fmt.Fprintf(&b, "D3/%d'24\nT-4.50\nPStarbucks\n^\n", day)
+	}
+	return b.String()
+}
+
+func TestFindDuplicatesRepeatedDailyPurchase(t *testing.T) {
+	qif := parseQIF(dailyPurchases())
+	clusters := findDuplicates(qif, 1, true)
+	for i, cluster := range clusters {
+		if len(cluster.Members) > 2 {
+			t.Errorf("cluster %d has %d members, want at most 2", i+1, len(cluster.Members))
+		}
+		first := cluster.Members[0].Transaction.Date
+		last := cluster.Members[len(cluster.Members)-1].Transaction.Date
+		if days := last.Sub(first).Hours() / 24; days > 1 {
+			t.Errorf("cluster %d spans %v days, want at most 1", i+1, days)
+		}
+	}
+
+	if removed := removeDuplicates(qif, clusters, true); removed != 0 {
+		t.Errorf("exact dedupe removed %d transactions, want 0", removed)
+	}
+	if got := len(qif.Accounts[0].Transactions); got != 10 {
+		t.Errorf("%d transactions left, want 10", got)
+	}
+}
+
+func TestFindDuplicatesSameDay(t *testing.T) {
+	qif := parseQIF(dailyPurchases())
+	if clusters := findDuplicates(qif, 0, true); len(clusters) != 0 {
+		t.Errorf("found %d clusters with days=0, want 0", len(clusters))
+	}
+}
+
+func TestFindDuplicatesExactAndFuzzy(t *testing.T) {
+	qif := parseQIF(`!Account
+NChecking
+TBank
+^
+!Type:Bank
+D3/1'24
+T-12.00
+PCorner Deli
+^
+D3/1'24
+T-12.00
+CX
+PCORNER DELI
+^
+D3/2'24
+T-12.00
+PCorner Deli
+^
+D3/9'24
+T-12.00
+PCorner Deli
+^
+`)
+	clusters := findDuplicates(qif, 1, true)
+	if len(clusters) != 1 {
+		t.Fatalf("found %d clusters, want 1", len(clusters))
+	}
+	if got := len(clusters[0].Members); got != 3 {
+		t.Fatalf("cluster has %d members, want 3", got)
+	}
+	if got := len(clusters[0].exactGroups()); got != 1 {
+		t.Errorf("cluster has %d exact groups, want 1", got)
+	}
+
+	// The cleared copy is the one kept
+	removed := removeDuplicates(qif, clusters, true)
+	if removed != 1 {
+		t.Errorf("exact dedupe removed %d transactions, want 1", removed)
+	}
+	transactions := qif.Accounts[0].Transactions
+	if len(transactions) != 3 || transactions[0].Cleared != "X" {
+		t.Errorf("unexpected transactions left after dedupe: %d, first cleared %q", len(transactions), transactions[0].Cleared)
+	}
+}
+
+func TestFindDuplicatesAcrossAccounts(t *testing.T) {
+	content := `!Account
+NChecking
+TBank
+^
+!Type:Bank
+D3/1'24
+T-30.00
+PGas Co
+^
+!Account
+NVisa
+TCCard
+^
+!Type:CCard
+D3/1'24
+T-30.00
+PGas Co
+^
+`
+	if clusters := findDuplicates(parseQIF(content), 1, false); len(clusters) != 0 {
+		t.Errorf("found %d clusters within accounts, want 0", len(clusters))
+	}
+	if clusters := findDuplicates(parseQIF(content), 1, true); len(clusters) != 1 {
+		t.Errorf("found %d clusters across accounts, want 1", len(clusters))
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// transactionFilter holds convert's filter flags. Zero values mean the filter
+// is not applied.
+type transactionFilter struct {
+	From            time.Time
+	To              time.Time
+	Accounts        []string
+	ExcludeAccounts []string
+	MinAmount       *money
+	MaxAmount       *money
+	ClearedOnly     bool
+	PayeeMatch      *regexp.Regexp
+}
+
+// newTransactionFilter parses the filter flag values. Dates are YYYY-MM-DD,
+// account lists are comma separated names, types or globs, and the payee
+// match is a case-insensitive regular expression.
+func newTransactionFilter(from, to, accounts, excludeAccounts, minAmount, maxAmount string, clearedOnly bool, payeeMatch string) (transactionFilter, error) {
+	filter := transactionFilter{
+		Accounts:        splitList(accounts),
+		ExcludeAccounts: splitList(excludeAccounts),
+		ClearedOnly:     clearedOnly,
+	}
+
+	var err error
+	if from != "" {
+		filter.From, err = time.Parse("2006-01-02", from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -from date: %s", from)
+		}
+	}
+	if to != "" {
+		filter.To, err = time.Parse("2006-01-02", to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -to date: %s", to)
+		}
+	}
+	if minAmount != "" {
+		amount, err := parseMoney(minAmount)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -min-amount: %s", err)
+		}
+		filter.MinAmount = &amount
+	}
+	if maxAmount != "" {
+		amount, err := parseMoney(maxAmount)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -max-amount: %s", err)
+		}
+		filter.MaxAmount = &amount
+	}
+	if payeeMatch != "" {
+		filter.PayeeMatch, err = regexp.Compile("(?i)" + payeeMatch)
+		if err != nil {
+			return filter, fmt.Errorf("invalid -payee-match: %s", err)
+		}
+	}
+	return filter, nil
+}
+
+// includeAccount applies the -accounts and -exclude-accounts filters.
+func (f transactionFilter) includeAccount(account *qifAccount) bool {
+	if len(f.Accounts) > 0 && !matchesAccount(account, f.Accounts) {
+		return false
+	}
+	return !matchesAccount(account, f.ExcludeAccounts)
+}
+
+// includeTransaction applies the date, amount, cleared and payee filters.
+// Amount limits compare the size of the amount, ignoring its sign.
+func (f transactionFilter) includeTransaction(t *qifTransaction) bool {
+	if !f.From.IsZero() && t.Date.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && t.Date.After(f.To) {
+		return false
+	}
+	if f.MinAmount != nil && t.Amount.Abs() < *f.MinAmount {
+		return false
+	}
+	if f.MaxAmount != nil && t.Amount.Abs() > *f.MaxAmount {
+		return false
+	}
+	if f.ClearedOnly && !isCleared(t.Cleared) {
+		return false
+	}
+	if f.PayeeMatch != nil && !f.PayeeMatch.MatchString(t.Payee) {
+		return false
+	}
+	return true
+}
+
+// filterTransactions drops the accounts and transactions the filter rejects
+// and returns how many transactions were removed.
+func filterTransactions(qif *qifFile, filter transactionFilter) int {
+	filtered := 0
+	var accounts []*qifAccount
+	for _, account := range qif.Accounts {
+		if !filter.includeAccount(account) {
+			filtered += len(account.Transactions)
+			continue
+		}
+		accounts = append(accounts, account)
+		var kept []*qifTransaction
+		for _, t := range account.Transactions {
+			if filter.includeTransaction(t) {
+				kept = append(kept, t)
+			} else {
+				filtered++
+			}
+		}
+		account.Transactions = kept
+	}
+	qif.Accounts = accounts
+	return filtered
+}
+
+// isCleared reports whether a C line marks the transaction cleared ("*" or
+// "c") or reconciled ("X" or "R").
+func isCleared(cleared string) bool {
+	switch strings.ToUpper(strings.TrimSpace(cleared)) {
+	case "*", "C", "X", "R":
+		return true
+	}
+	return false
+}
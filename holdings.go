@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// holdingLot is shares bought together. With the average method a position
+// has a single lot holding all shares and their total cost. Shares and prices
+// are floats; amounts are money so cost basis and gains add up exactly.
+type holdingLot struct {
+	Date   time.Time
+	Shares float64
+	Cost   money
+}
+
+// holdingPosition is one security in one account.
+type holdingPosition struct {
+	Account  string
+	Security string
+	Lots     []*holdingLot
+	// LastPrice is the price of the latest transaction, used when the price
+	// list has nothing for the security
+	LastPrice     float64
+	LastPriceDate time.Time
+}
+
+func (p *holdingPosition) shares() float64 {
+	var shares float64
+	for _, lot := range p.Lots {
+		shares += lot.Shares
+	}
+	return shares
+}
+
+func (p *holdingPosition) cost() money {
+	var cost money
+	for _, lot := range p.Lots {
+		cost += lot.Cost
+	}
+	return cost
+}
+
+// realizedGain is the result of selling shares from one lot, or with the
+// average method from the whole position.
+type realizedGain struct {
+	Account  string
+	Security string
+	Sold     time.Time
+	Acquired time.Time
+	Shares   float64
+	Proceeds money
+	Cost     money
+}
+
+// holdingShareEpsilon absorbs float rounding when a position is sold out.
+const holdingShareEpsilon = 1e-6
+
+// runHoldings is the holdings subcommand: it replays the investment
+// transactions to get current positions, cost basis and realized gains.
+func runHoldings(args []string) {
+	holdingsCmd := flag.NewFlagSet("holdings", flag.ExitOnError)
+	var inputFile inputFileList
+	holdingsCmd.Var(&inputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
+	method := holdingsCmd.String("method", "fifo", "cost basis method: fifo or average")
+	outputFile := holdingsCmd.String("outputfile", "holdings.csv", "current positions CSV")
+	gainsFile := holdingsCmd.String("gainsfile", "realizedGains.csv", "realized gains per sale CSV")
+	asOf := holdingsCmd.String("asof", "", "replay transactions and prices up to this date (YYYY-MM-DD, default all)")
+	holdingsCmd.Parse(args)
+	fmt.Println("subcommand 'holdings'")
+	fmt.Println("	inputfile:", inputFile.String())
+	fmt.Println("	method:", *method)
+	fmt.Println("	outputfile:", *outputFile)
+	fmt.Println("	gainsfile:", *gainsFile)
+	fmt.Println("	asof:", *asOf)
+
+	if *method != "fifo" && *method != "average" {
+		fmt.Println("unknown cost basis method:", *method, "(expected fifo or average)")
+		os.Exit(1)
+	}
+	var asOfDate time.Time
+	if *asOf != "" {
+		var err error
+		asOfDate, err = time.Parse("2006-01-02", *asOf)
+		if err != nil {
+			fmt.Println("invalid -asof date:", *asOf)
+			os.Exit(1)
+		}
+	}
+
+	qif, err := loadQIFFiles(inputFile, nil)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+
+	positions, gains, warnings := replayInvestments(qif, *method, asOfDate)
+	for _, warning := range warnings {
+		fmt.Println("Warning:", warning)
+	}
+
+	err = writeHoldingsCSV(*outputFile, qif, positions, asOfDate)
+	if err != nil {
+		fmt.Println("Error writing holdings:", err)
+		return
+	}
+	err = writeRealizedGainsCSV(*gainsFile, gains, *method)
+	if err != nil {
+		fmt.Println("Error writing realized gains:", err)
+		return
+	}
+	fmt.Println("Holdings written:", *outputFile, "sales:", len(gains))
+}
+
+// investmentAction strips the X Quicken adds to actions that move cash to
+// or from another account, so BuyX is handled as Buy.
+func investmentAction(action string) string {
+	action = strings.ToLower(strings.TrimSpace(action))
+	if len(action) > 1 {
+		action = strings.TrimSuffix(action, "x")
+	}
+	return action
+}
+
+// replayInvestments applies each investment account's transactions in date
+// order. Buys, ShrsIn and reinvestments add shares at the transaction's
+// amount (or shares times price plus commission), sales take shares out in
+// FIFO order or at the average cost, ShrsOut removes shares without a gain,
+// and StkSplit multiplies the shares by Q/10.
+func replayInvestments(qif *qifFile, method string, asOf time.Time) ([]*holdingPosition, []realizedGain, []string) {
+	var positions []*holdingPosition
+	var gains []realizedGain
+	var warnings []string
+
+	for _, account := range qif.Accounts {
+		investments := append([]*qifInvestment(nil), account.Investments...)
+		sort.SliceStable(investments, func(i, j int) bool {
+			return investments[i].Date.Before(investments[j].Date)
+		})
+
+		bySecurity := make(map[string]*holdingPosition)
+		for _, inv := range investments {
+			if inv.Security == "" || (!asOf.IsZero() && inv.Date.After(asOf)) {
+				continue
+			}
+			position := bySecurity[inv.Security]
+			if position == nil {
+				position = &holdingPosition{Account: account.Name, Security: inv.Security}
+				bySecurity[inv.Security] = position
+				positions = append(positions, position)
+			}
+			if inv.Price > 0 && !inv.Date.Before(position.LastPriceDate) {
+				position.LastPrice = inv.Price
+				position.LastPriceDate = inv.Date
+			}
+
+			shares := math.Abs(inv.Quantity)
+			value := inv.Amount.Abs()
+			action := investmentAction(inv.Action)
+			switch action {
+			case "buy", "shrsin", "reinvdiv", "reinvint", "reinvlg", "reinvmd", "reinvsh":
+				if value == 0 {
+					value = dollars(shares * inv.Price)
+					if action == "buy" {
+						value += inv.Commission
+					}
+				}
+				if method == "average" && len(position.Lots) > 0 {
+					position.Lots[0].Shares += shares
+					position.Lots[0].Cost += value
+				} else {
+					position.Lots = append(position.Lots, &holdingLot{Date: inv.Date, Shares: shares, Cost: value})
+				}
+			case "sell", "shrsout":
+				if value == 0 {
+					value = dollars(shares*inv.Price) - inv.Commission
+				}
+				if shares > position.shares()+holdingShareEpsilon {
+					warnings = append(warnings, fmt.Sprintf("account %s, %s: %s of %s shares of %s, only %s held",
+						account.Name, inv.RawDate, inv.Action, formatShares(shares), inv.Security, formatShares(position.shares())))
+				}
+				sold := removeShares(position, shares)
+				if action == "shrsout" {
+					continue
+				}
+				// Proceeds are shared out by shares sold from each lot, with
+				// the last lot taking the rounding
+				remaining := value
+				for i, lot := range sold {
+					proceeds := remaining
+					if i < len(sold)-1 && shares > 0 {
+						proceeds = dollars(value.float() * lot.Shares / shares)
+					}
+					remaining -= proceeds
+					gains = append(gains, realizedGain{
+						Account:  account.Name,
+						Security: inv.Security,
+						Sold:     inv.Date,
+						Acquired: lot.Date,
+						Shares:   lot.Shares,
+						Proceeds: proceeds,
+						Cost:     lot.Cost,
+					})
+				}
+			case "stksplit":
+				ratio := inv.Quantity / 10
+				if ratio <= 0 {
+					warnings = append(warnings, fmt.Sprintf("account %s, %s: invalid split ratio for %s", account.Name, inv.RawDate, inv.Security))
+					continue
+				}
+				for _, lot := range position.Lots {
+					lot.Shares *= ratio
+				}
+				if position.LastPrice > 0 {
+					position.LastPrice /= ratio
+				}
+			}
+		}
+	}
+	return positions, gains, warnings
+}
+
+// removeShares takes shares out of the position's lots, oldest first, and
+// returns what was taken from each lot with its share of the cost. Shares
+// sold beyond what is held have no cost.
+func removeShares(position *holdingPosition, shares float64) []*holdingLot {
+	var sold []*holdingLot
+	remaining := shares
+	for len(position.Lots) > 0 && remaining > holdingShareEpsilon {
+		lot := position.Lots[0]
+		if lot.Shares <= remaining+holdingShareEpsilon {
+			sold = append(sold, lot)
+			remaining -= lot.Shares
+			position.Lots = position.Lots[1:]
+			continue
+		}
+		cost := dollars(lot.Cost.float() * remaining / lot.Shares)
+		sold = append(sold, &holdingLot{Date: lot.Date, Shares: remaining, Cost: cost})
+		lot.Shares -= remaining
+		lot.Cost -= cost
+		remaining = 0
+	}
+	if remaining > holdingShareEpsilon {
+		sold = append(sold, &holdingLot{Shares: remaining})
+	}
+	return sold
+}
+
+// latestPrices returns the most recent price list entry for each security,
+// keyed by both symbol and security name, up to asOf.
+func latestPrices(qif *qifFile, asOf time.Time) map[string]*qifPrice {
+	names := make(map[string][]string)
+	for _, security := range qif.Securities {
+		if security.Symbol != "" {
+			names[security.Symbol] = append(names[security.Symbol], security.Name)
+		}
+	}
+	latest := make(map[string]*qifPrice)
+	for _, price := range qif.Prices {
+		if !asOf.IsZero() && price.Date.After(asOf) {
+			continue
+		}
+		for _, key := range append([]string{price.Security}, names[price.Security]...) {
+			if current := latest[key]; current == nil || !price.Date.Before(current.Date) {
+				latest[key] = price
+			}
+		}
+	}
+	return latest
+}
+
+func writeHoldingsCSV(outputFileName string, qif *qifFile, positions []*holdingPosition, asOf time.Time) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	prices := latestPrices(qif, asOf)
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Account", "Security", "Shares", "Cost Basis", "Average Cost", "Price", "Price Date", "Market Value", "Unrealized Gain"})
+	for _, position := range positions {
+		shares := position.shares()
+		if math.Abs(shares) < holdingShareEpsilon {
+			continue
+		}
+		cost := position.cost()
+		price, priceDate := position.LastPrice, position.LastPriceDate
+		if listed := prices[position.Security]; listed != nil && !listed.Date.Before(priceDate) {
+			price, priceDate = listed.Price, listed.Date
+		}
+		value := dollars(shares * price)
+		date := ""
+		if !priceDate.IsZero() {
+			date = priceDate.Format("2006-01-02")
+		}
+		writer.Write([]string{
+			position.Account,
+			position.Security,
+			formatShares(shares),
+			cost.String(),
+			strconv.FormatFloat(cost.float()/shares, 'f', 4, 64),
+			strconv.FormatFloat(price, 'f', -1, 64),
+			date,
+			value.String(),
+			(value - cost).String(),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeRealizedGainsCSV writes a row per lot sold. Term is long for FIFO lots
+// held more than a year; the average method does not track holding periods.
+func writeRealizedGainsCSV(outputFileName string, gains []realizedGain, method string) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Account", "Security", "Sale Date", "Acquired", "Shares", "Proceeds", "Cost Basis", "Gain", "Term"})
+	for _, gain := range gains {
+		acquired, term := "", ""
+		if method == "fifo" && !gain.Acquired.IsZero() {
+			acquired = gain.Acquired.Format("2006-01-02")
+			term = "short"
+			if gain.Sold.After(gain.Acquired.AddDate(1, 0, 0)) {
+				term = "long"
+			}
+		}
+		writer.Write([]string{
+			gain.Account,
+			gain.Security,
+			gain.Sold.Format("2006-01-02"),
+			acquired,
+			formatShares(gain.Shares),
+			gain.Proceeds.String(),
+			gain.Cost.String(),
+			(gain.Proceeds - gain.Cost).String(),
+			term,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatShares writes a share count with up to six decimals.
+func formatShares(shares float64) string {
+	value := strconv.FormatFloat(shares, 'f', 6, 64)
+	value = strings.TrimRight(strings.TrimRight(value, "0"), ".")
+	if value == "-0" {
+		return "0"
+	}
+	return value
+}
+
+// dollars rounds a float amount to money.
+func dollars(amount float64) money {
+	return money(math.Round(amount * 100))
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+const holdingsFixture = `!Account
+NBrokerage
+TInvst
+^
+!Type:Invst
+D1/ 5'22
+NBuy
+YACME
+I10
+Q10
+T105.00
+O5.00
+^
+D6/ 1'22
+NBuy
+YACME
+I20
+Q10
+T200.00
+^
+D3/ 1'23
+NSell
+YACME
+I30
+Q15
+T450.00
+^
+`
+
+func replayFixture(t *testing.T, content string, method string) ([]*holdingPosition, []realizedGain, []string) {
+	t.Helper()
+	qif := parseQIF(content)
+	if len(qif.Warnings) > 0 {
+		t.Fatalf("fixture has warnings: %v", qif.Warnings)
+	}
+	return replayInvestments(qif, method, time.Time{})
+}
+
+func TestReplayInvestmentsFIFO(t *testing.T) {
+	positions, gains, warnings := replayFixture(t, holdingsFixture, "fifo")
+	if len(warnings) > 0 {
+		t.Errorf("unexpected warnings: %v", warnings)
+	}
+	if len(positions) != 1 || math.Abs(positions[0].shares()-5) > holdingShareEpsilon || positions[0].cost() != 10000 {
+		t.Fatalf("position %v shares at %s, want 5 at 100.00", positions[0].shares(), positions[0].cost())
+	}
+	// The first lot is sold whole and half of the second
+	want := []realizedGain{
+		{Shares: 10, Proceeds: 30000, Cost: 10500},
+		{Shares: 5, Proceeds: 15000, Cost: 10000},
+	}
+	if len(gains) != len(want) {
+		t.Fatalf("got %d gains, want %d", len(gains), len(want))
+	}
+	for i, w := range want {
+		if gains[i].Shares != w.Shares || gains[i].Proceeds != w.Proceeds || gains[i].Cost != w.Cost {
+			t.Errorf("gain %d: %v shares, proceeds %s, cost %s; want %v, %s, %s", i,
+				gains[i].Shares, gains[i].Proceeds, gains[i].Cost, w.Shares, w.Proceeds, w.Cost)
+		}
+	}
+	if got := gains[0].Acquired.Format("2006-01-02"); got != "2022-01-05" {
+		t.Errorf("first gain acquired %s, want 2022-01-05", got)
+	}
+}
+
+func TestReplayInvestmentsAverage(t *testing.T) {
+	positions, gains, _ := replayFixture(t, holdingsFixture, "average")
+	// 20 shares cost 305.00; 15 of them carry 228.75 of it
+	if len(gains) != 1 || gains[0].Shares != 15 || gains[0].Proceeds != 45000 || gains[0].Cost != 22875 {
+		t.Fatalf("gains %+v, want one sale of 15 shares costing 228.75", gains)
+	}
+	if positions[0].cost() != 7625 {
+		t.Errorf("remaining cost %s, want 76.25", positions[0].cost())
+	}
+}
+
+func TestReplayInvestmentsProceedsAddUp(t *testing.T) {
+	// Three lots of one share sold for 100.00 cannot be split evenly; the
+	// parts must still add up to the sale amount
+	content := `!Account
+NBrokerage
+TInvst
+^
+!Type:Invst
+D1/ 1'23
+NBuy
+YACME
+Q1
+T10.00
+^
+D1/ 2'23
+NBuy
+YACME
+Q1
+T10.00
+^
+D1/ 3'23
+NBuy
+YACME
+Q1
+T10.00
+^
+D2/ 1'23
+NSell
+YACME
+Q3
+T100.00
+^
+`
+	_, gains, _ := replayFixture(t, content, "fifo")
+	var total money
+	for _, gain := range gains {
+		total += gain.Proceeds
+	}
+	if len(gains) != 3 || total != 10000 {
+		t.Errorf("%d gains with proceeds adding up to %s, want 3 adding up to 100.00", len(gains), total)
+	}
+}
+
+func TestReplayInvestmentsStockSplit(t *testing.T) {
+	content := holdingsFixture + `D4/ 1'23
+NStkSplit
+YACME
+Q20
+^
+D4/ 2'23
+NSell
+YACME
+I20
+Q10
+T200.00
+^
+`
+	positions, gains, _ := replayFixture(t, content, "fifo")
+	// 5 shares at a cost of 100.00 became 10; selling all of them realizes
+	// the whole cost
+	if shares := positions[0].shares(); math.Abs(shares) > holdingShareEpsilon {
+		t.Errorf("%v shares left, want 0", shares)
+	}
+	last := gains[len(gains)-1]
+	if last.Shares != 10 || last.Cost != 10000 || last.Proceeds != 20000 {
+		t.Errorf("sale after split: %v shares, cost %s, proceeds %s; want 10, 100.00, 200.00", last.Shares, last.Cost, last.Proceeds)
+	}
+}
+
+func TestReplayInvestmentsOversell(t *testing.T) {
+	content := holdingsFixture + `D5/ 1'23
+NSell
+YACME
+I30
+Q10
+T300.00
+^
+`
+	positions, gains, warnings := replayFixture(t, content, "fifo")
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "only 5 held") {
+		t.Errorf("warnings %v, want one about selling more than held", warnings)
+	}
+	// The 5 shares held keep their cost; the other 5 have none
+	last := gains[len(gains)-2:]
+	if last[0].Shares != 5 || last[0].Cost != 10000 || last[1].Shares != 5 || last[1].Cost != 0 {
+		t.Errorf("oversold gains %+v", last)
+	}
+	if last[0].Proceeds+last[1].Proceeds != 30000 {
+		t.Errorf("proceeds add up to %s, want 300.00", last[0].Proceeds+last[1].Proceeds)
+	}
+	if shares := positions[0].shares(); math.Abs(shares) > holdingShareEpsilon {
+		t.Errorf("%v shares left, want 0", shares)
+	}
+}
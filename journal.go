@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// journalPosting is one leg of a double-entry transaction.
+type journalPosting struct {
+	Account string
+	Amount  money
+	Memo    string
+}
+
+// journalEntry is a balanced transaction for the plain-text accounting
+// formats.
+type journalEntry struct {
+	Date     time.Time
+	Cleared  bool
+	Number   string
+	Payee    string
+	Memo     string
+	Tag      string
+	Postings []journalPosting
+}
+
+// buildJournal turns each register transaction into a balanced entry: the
+// register account against its category, one posting per split, and
+// transfers as account to account. A transfer appears in both registers in
+// QIF, so it is only taken from the account that comes first in the file,
+// split legs included, when the other side is also being exported.
+func buildJournal(qif *qifFile, accountMapping map[string]string, ledgerMapping map[string]string, beancount bool) ([]journalEntry, map[string]time.Time) {
+	categoryInfo := make(map[string]*qifCategory)
+	for _, category := range qif.Categories {
+		categoryInfo[category.Name] = category
+	}
+	accountInfo := make(map[string]*qifAccount)
+	position := make(map[string]int)
+	for i, account := range qif.Accounts {
+		accountInfo[account.Name] = account
+		if len(account.Transactions) > 0 {
+			position[account.Name] = i
+		}
+	}
+
+	registerAccount := func(name string) string {
+		if len(ledgerMapping[name]) > 0 {
+			return ledgerMapping[name]
+		}
+		prefix := "Assets"
+		if account := accountInfo[name]; account != nil && isLiabilityAccount(account) {
+			prefix = "Liabilities"
+		}
+		return journalAccountName(prefix+":"+ofxAccountName(name, accountMapping), beancount)
+	}
+	categoryAccount := func(value string, amount money) string {
+		category, _ := splitCategoryAndTag(value)
+		if len(ledgerMapping[category]) > 0 {
+			return ledgerMapping[category]
+		}
+		if category == "" {
+			category = "Uncategorized"
+		}
+		prefix := "Expenses"
+		if info := categoryInfo[category]; info != nil {
+			if info.Income {
+				prefix = "Income"
+			}
+		} else if amount > 0 {
+			prefix = "Income"
+		}
+		return journalAccountName(prefix+":"+category, beancount)
+	}
+	transferAccount := func(value string) (string, bool) {
+		if strings.HasPrefix(value, "[") {
+			if end := strings.Index(value, "]"); end > 0 {
+				return value[1:end], true
+			}
+		}
+		return "", false
+	}
+
+	var entries []journalEntry
+	opened := make(map[string]time.Time)
+	open := func(account string, date time.Time) {
+		if first, ok := opened[account]; !ok || date.Before(first) {
+			opened[account] = date
+		}
+	}
+
+	for i, account := range qif.Accounts {
+		if len(account.Transactions) == 0 {
+			continue
+		}
+		register := registerAccount(account.Name)
+		for _, t := range account.Transactions {
+			_, tag := splitCategoryAndTag(t.Category)
+			entry := journalEntry{
+				Date:    t.Date,
+				Cleared: isCleared(t.Cleared),
+				Number:  t.Number,
+				Payee:   t.Payee,
+				Memo:    t.Memo,
+				Tag:     tag,
+			}
+			entry.Postings = append(entry.Postings, journalPosting{Account: register, Amount: t.Amount})
+
+			if len(t.Splits) == 0 {
+				if other, ok := transferAccount(t.Category); ok {
+					if otherPosition, exported := position[other]; exported && otherPosition < i {
+						continue
+					}
+					entry.Postings = append(entry.Postings, journalPosting{Account: registerAccount(other), Amount: -t.Amount})
+				} else {
+					entry.Postings = append(entry.Postings, journalPosting{Account: categoryAccount(t.Category, t.Amount), Amount: -t.Amount})
+				}
+			}
+			for _, split := range t.Splits {
+				target := categoryAccount(split.Category, split.Amount)
+				if other, ok := transferAccount(split.Category); ok {
+					// The earlier account already posted this leg of the
+					// transfer, so it comes off this entry's register posting
+					if otherPosition, exported := position[other]; exported && otherPosition < i {
+						entry.Postings[0].Amount -= split.Amount
+						continue
+					}
+					target = registerAccount(other)
+				}
+				entry.Postings = append(entry.Postings, journalPosting{Account: target, Amount: -split.Amount, Memo: split.Memo})
+			}
+			if len(entry.Postings) == 1 {
+				continue
+			}
+
+			for _, posting := range entry.Postings {
+				open(posting.Account, t.Date)
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date)
+	})
+	return entries, opened
+}
+
+// journalAccountName cleans up an account path. Beancount only allows
+// letters, digits and dashes in each component, which must start with a
+// capital letter or digit.
+func journalAccountName(name string, beancount bool) string {
+	if !beancount {
+		return name
+	}
+	var components []string
+	for _, component := range strings.Split(name, ":") {
+		var b strings.Builder
+		for _, r := range strings.TrimSpace(component) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+				b.WriteRune(r)
+			} else {
+				b.WriteRune('-')
+			}
+		}
+		cleaned := strings.Trim(b.String(), "-")
+		if cleaned == "" {
+			cleaned = "Unknown"
+		}
+		runes := []rune(cleaned)
+		if !unicode.IsDigit(runes[0]) {
+			runes[0] = unicode.ToUpper(runes[0])
+		}
+		if !unicode.IsUpper(runes[0]) && !unicode.IsDigit(runes[0]) {
+			runes = append([]rune("X"), runes...)
+		}
+		components = append(components, string(runes))
+	}
+	return strings.Join(components, ":")
+}
+
+// writeJournalFile writes a ledger/hledger journal or a beancount file.
+func writeJournalFile(qif *qifFile, accountMapping map[string]string, ledgerMappingFile string, outputFileName string, beancount bool) error {
+	var ledgerMapping map[string]string
+	if ledgerMappingFile != "" {
+		var err error
+		ledgerMapping, err = loadMapping(ledgerMappingFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	entries, opened := buildJournal(qif, accountMapping, ledgerMapping, beancount)
+
+	var accounts []string
+	for account := range opened {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	var b strings.Builder
+	if beancount {
+		b.WriteString("option \"operating_currency\" \"USD\"\n\n")
+		for _, account := range accounts {
+			fmt.Fprintf(&b, "%s open %s\n", opened[account].Format("2006-01-02"), account)
+		}
+	} else {
+		for _, account := range accounts {
+			fmt.Fprintf(&b, "account %s\n", account)
+		}
+	}
+	b.WriteString("\n")
+
+	for _, entry := range entries {
+		if beancount {
+			flag := "!"
+			if entry.Cleared {
+				flag = "*"
+			}
+			fmt.Fprintf(&b, "%s %s %s %s", entry.Date.Format("2006-01-02"), flag, beancountString(entry.Payee), beancountString(entry.Memo))
+			if entry.Tag != "" {
+				b.WriteString(" #" + journalAccountName(entry.Tag, true))
+			}
+			b.WriteString("\n")
+			if entry.Number != "" {
+				fmt.Fprintf(&b, "  number: %s\n", beancountString(entry.Number))
+			}
+		} else {
+			b.WriteString(entry.Date.Format("2006/01/02"))
+			if entry.Cleared {
+				b.WriteString(" *")
+			}
+			if entry.Number != "" {
+				b.WriteString(" (" + entry.Number + ")")
+			}
+			b.WriteString(" " + entry.Payee)
+			if entry.Memo != "" {
+				b.WriteString("  ; " + entry.Memo)
+			}
+			b.WriteString("\n")
+			if entry.Tag != "" {
+				b.WriteString("    ; :" + entry.Tag + ":\n")
+			}
+		}
+		for _, posting := range entry.Postings {
+			fmt.Fprintf(&b, "    %-40s  %12s USD", posting.Account, posting.Amount.String())
+			if posting.Memo != "" {
+				b.WriteString("  ; " + posting.Memo)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+
+	err := os.WriteFile(outputFileName, []byte(b.String()), 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Journal written:", outputFileName, "entries:", len(entries))
+	return nil
+}
+
+func beancountString(value string) string {
+	return "\"" + strings.ReplaceAll(strings.ReplaceAll(value, "\\", "\\\\"), "\"", "\\\"") + "\""
+}
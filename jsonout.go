@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// jsonSchemaVersion is written into every json and ndjson document. Bump it
+// when a field is renamed or removed or its meaning changes; adding fields
+// does not need a new version.
+const jsonSchemaVersion = 1
+
+// jsonDocument is the -format json output: the whole parsed file.
+type jsonDocument struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Accounts      []jsonAccount   `json:"accounts"`
+	Categories    []jsonCategory  `json:"categories"`
+	Tags          []jsonNamedItem `json:"tags"`
+	Classes       []jsonNamedItem `json:"classes"`
+}
+
+type jsonAccount struct {
+	Name         string            `json:"name"`
+	OriginalName string            `json:"originalName"`
+	Type         string            `json:"type"`
+	Description  string            `json:"description,omitempty"`
+	CreditLimit  string            `json:"creditLimit,omitempty"`
+	Balance      string            `json:"balance,omitempty"`
+	BalanceDate  string            `json:"balanceDate,omitempty"`
+	Transactions []jsonTransaction `json:"transactions"`
+}
+
+type jsonCategory struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Income      bool     `json:"income"`
+	Expense     bool     `json:"expense"`
+	TaxRelated  bool     `json:"taxRelated"`
+	TaxSchedule string   `json:"taxSchedule,omitempty"`
+	Budget      []string `json:"budget,omitempty"`
+}
+
+type jsonNamedItem struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// jsonTransaction is a register transaction. Amounts are decimal strings so
+// no precision is lost, and Date is YYYY-MM-DD (RawDate keeps the QIF text).
+// The ndjson lines use the same object with SchemaVersion and Account set.
+type jsonTransaction struct {
+	SchemaVersion    int         `json:"schemaVersion,omitempty"`
+	Account          string      `json:"account,omitempty"`
+	Date             string      `json:"date"`
+	RawDate          string      `json:"rawDate"`
+	Amount           string      `json:"amount"`
+	Cleared          string      `json:"cleared,omitempty"`
+	Number           string      `json:"number,omitempty"`
+	Payee            string      `json:"payee"`
+	OriginalPayee    string      `json:"originalPayee"`
+	Category         string      `json:"category"`
+	Tag              string      `json:"tag,omitempty"`
+	OriginalCategory string      `json:"originalCategory"`
+	Memo             string      `json:"memo,omitempty"`
+	Address          []string    `json:"address,omitempty"`
+	Splits           []jsonSplit `json:"splits,omitempty"`
+	Source           string      `json:"source,omitempty"`
+}
+
+type jsonSplit struct {
+	Category string `json:"category"`
+	Tag      string `json:"tag,omitempty"`
+	Memo     string `json:"memo,omitempty"`
+	Amount   string `json:"amount"`
+	Percent  string `json:"percent,omitempty"`
+}
+
+func newJSONTransaction(t *qifTransaction) jsonTransaction {
+	category, tag := splitCategoryAndTag(t.Category)
+	row := jsonTransaction{
+		RawDate:          t.RawDate,
+		Amount:           t.Amount.String(),
+		Cleared:          t.Cleared,
+		Number:           t.Number,
+		Payee:            t.Payee,
+		OriginalPayee:    t.OriginalPayee,
+		Category:         category,
+		Tag:              tag,
+		OriginalCategory: t.OriginalCategory,
+		Memo:             t.Memo,
+		Address:          t.Address,
+		Source:           t.Source,
+	}
+	if !t.Date.IsZero() {
+		row.Date = t.Date.Format("2006-01-02")
+	}
+	for _, split := range t.Splits {
+		category, tag := splitCategoryAndTag(split.Category)
+		row.Splits = append(row.Splits, jsonSplit{
+			Category: category,
+			Tag:      tag,
+			Memo:     split.Memo,
+			Amount:   split.Amount.String(),
+			Percent:  split.Percent,
+		})
+	}
+	return row
+}
+
+// writeJSONFile writes the accounts with their transactions nested, followed
+// by the category, tag and class lists.
+func writeJSONFile(qif *qifFile, accountMapping map[string]string, outputFileName string) error {
+	document := jsonDocument{
+		SchemaVersion: jsonSchemaVersion,
+		Accounts:      []jsonAccount{},
+		Categories:    []jsonCategory{},
+		Tags:          []jsonNamedItem{},
+		Classes:       []jsonNamedItem{},
+	}
+	for _, account := range qif.Accounts {
+		row := jsonAccount{
+			Name:         ofxAccountName(account.Name, accountMapping),
+			OriginalName: account.Name,
+			Type:         account.Type,
+			Description:  account.Description,
+			CreditLimit:  account.CreditLimit,
+			Balance:      account.Balance,
+			BalanceDate:  account.BalanceDate,
+			Transactions: []jsonTransaction{},
+		}
+		for _, t := range account.Transactions {
+			row.Transactions = append(row.Transactions, newJSONTransaction(t))
+		}
+		document.Accounts = append(document.Accounts, row)
+	}
+	for _, c := range qif.Categories {
+		row := jsonCategory{
+			Name:        c.Name,
+			Description: c.Description,
+			Income:      c.Income,
+			Expense:     c.Expense,
+			TaxRelated:  c.TaxRelated,
+			TaxSchedule: c.TaxSchedule,
+		}
+		for _, amount := range c.Budget {
+			row.Budget = append(row.Budget, amount.String())
+		}
+		document.Categories = append(document.Categories, row)
+	}
+	for _, tag := range qif.Tags {
+		document.Tags = append(document.Tags, jsonNamedItem{Name: tag.Name, Description: tag.Description})
+	}
+	for _, class := range qif.Classes {
+		document.Classes = append(document.Classes, jsonNamedItem{Name: class.Name, Description: class.Description})
+	}
+
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(outputFileName, append(data, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Println("JSON file written:", outputFileName)
+	return nil
+}
+
+// writeNDJSONFile writes one transaction object per line, each carrying the
+// schema version and its (mapped) account name.
+func writeNDJSONFile(qif *qifFile, accountMapping map[string]string, outputFileName string) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	count := 0
+	for _, account := range qif.Accounts {
+		name := ofxAccountName(account.Name, accountMapping)
+		for _, t := range account.Transactions {
+			row := newJSONTransaction(t)
+			row.SchemaVersion = jsonSchemaVersion
+			row.Account = name
+			if err := encoder.Encode(row); err != nil {
+				return err
+			}
+			count++
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	fmt.Println("NDJSON file written:", outputFileName, "transactions:", count)
+	return nil
+}
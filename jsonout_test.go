@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewJSONTransactionTags(t *testing.T) {
+	transaction, errs := parseTransactionRecord(strings.Split("D3/ 5'24\nT-150.00\nPMarket\nLGroceries/Vacation\nSGroceries/Vacation\n$-120.00\nS[Visa]/Business\n$-30.00\nSHousehold\n$0.00", "\n"))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	row := newJSONTransaction(transaction)
+	if row.Category != "Groceries" || row.Tag != "Vacation" {
+		t.Errorf("transaction category %q, tag %q, want Groceries, Vacation", row.Category, row.Tag)
+	}
+	want := []jsonSplit{
+		{Category: "Groceries", Tag: "Vacation", Amount: "-120.00"},
+		{Category: "[Visa]", Tag: "Business", Amount: "-30.00"},
+		{Category: "Household", Amount: "0.00"},
+	}
+	if len(row.Splits) != len(want) {
+		t.Fatalf("got %d splits, want %d", len(row.Splits), len(want))
+	}
+	for i := range want {
+		if row.Splits[i] != want[i] {
+			t.Errorf("split %d is %+v, want %+v", i, row.Splits[i], want[i])
+		}
+	}
+}
@@ -12,17 +12,15 @@ import (
 
 func main() {
 	// Flag Variables
-	inputFileName := ""
-	outputFileName := ""
-	categoryMappingFile := ""
-	payeeMappingFile := ""
-	accountMappingFile := ""
+	var inputFileNames []string
+	convertOpts := convertOptions{}
 	extractCategoryFlag := false
 	extractPayeeFlag := false
 	extractTagFlag := false
 	extractAccountFlag := false
-
-	_ = outputFileName
+	extractAccountFormatValue := "txt"
+	extractMemorizedFlag := false
+	extractMemorizedRulesFlag := false
 
 	// Flagsets
 	extractCmd := flag.NewFlagSet("extract", flag.ExitOnError)
@@ -30,18 +28,45 @@ func main() {
 	extractPayee := extractCmd.Bool("payees", false, "payees")
 	extractTag := extractCmd.Bool("tags", false, "tags")
 	extractAccount := extractCmd.Bool("accounts", false, "accounts")
-	extractInputFile := extractCmd.String("inputfile", "", "inputfile")
+	var extractInputFile inputFileList
+	extractCmd.Var(&extractInputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
+	extractAccountFormat := extractCmd.String("accountsformat", "txt", "accounts output: txt (names only), csv or json report")
+	extractMemorized := extractCmd.Bool("memorized", false, "memorized")
+	extractMemorizedRules := extractCmd.Bool("memorizedrules", false, "write memorized payees as a payee,category rules file")
 
 	convertCmd := flag.NewFlagSet("convert", flag.ExitOnError)
-	convertInputFile := convertCmd.String("inputfile", "", "inputfile")
+	var convertInputFile inputFileList
+	convertCmd.Var(&convertInputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
 	convertAccountName := convertCmd.String("accountname", "", "accountname")
 	convertOutputFile := convertCmd.String("outputfile", "", "outputfile")
 	convertCategoryMapFile := convertCmd.String("categorymap", "", "categorymap")
 	convertPayeeMapFile := convertCmd.String("payeemap", "", "payeemap")
 	convertAccountMapFile := convertCmd.String("accountmap", "", "accountmap")
+	convertPayeeRulesFile := convertCmd.String("payeerules", "", "payee,category rules for uncategorized transactions")
+	convertInvertSigns := convertCmd.String("invertsigns", "", "comma separated account names or types (e.g. CCard) whose amount signs are flipped")
+	convertAmountStyle := convertCmd.String("amountstyle", "signed", "amount columns: signed, inflowoutflow or debitcredit")
+	convertDateFormat := convertCmd.String("dateformat", "iso", "date format: iso, us, eu, excel or a Go layout such as 2006/01/02")
+	convertFrom := convertCmd.String("from", "", "only transactions on or after this date (YYYY-MM-DD)")
+	convertTo := convertCmd.String("to", "", "only transactions on or before this date (YYYY-MM-DD)")
+	convertAccounts := convertCmd.String("accounts", "", "comma separated account names, types or globs to include")
+	convertExcludeAccounts := convertCmd.String("exclude-accounts", "", "comma separated account names, types or globs to exclude")
+	convertMinAmount := convertCmd.String("min-amount", "", "only transactions of at least this size, ignoring sign")
+	convertMaxAmount := convertCmd.String("max-amount", "", "only transactions of at most this size, ignoring sign")
+	convertClearedOnly := convertCmd.Bool("cleared-only", false, "only cleared or reconciled transactions")
+	convertPayeeMatch := convertCmd.String("payee-match", "", "only payees matching this regular expression")
+	convertStateFile := convertCmd.String("since-state", "", "JSON state file; only transactions not exported by a previous run are written")
+	convertStateGraceDays := convertCmd.Int("state-grace-days", 30, "days before the last export in which late or edited transactions are still exported")
+	convertDedupe := convertCmd.String("dedupe", "", "remove duplicate transactions: exact (same account and date) or fuzzy (also within -dedupe-days)")
+	convertDedupeDays := convertCmd.Int("dedupe-days", 1, "days apart that still count as a fuzzy duplicate")
+	convertDedupeAcross := convertCmd.Bool("dedupe-across-accounts", false, "also match duplicates in different accounts")
+	convertDedupeReview := convertCmd.String("dedupe-review", "", "with -dedupe exact, write the fuzzy matches left in place to this review CSV")
+	convertFormat := convertCmd.String("format", "csv", "output format: csv (one file per account), qif, ofx (OFX 2.x), ofx1 (SGML OFX 1.x), ledger, beancount, json, ndjson, sqlite or xlsx")
+	convertLedgerMapFile := convertCmd.String("ledgermap", "", "category or account name,ledger account mappings for ledger and beancount output")
+	convertBankID := convertCmd.String("bankid", "000000000", "routing number written as the BANKID of Bank accounts in ofx and ofx1 output")
+	convertSuggestCategories := convertCmd.Float64("suggest-categories", 0, "fill in uncategorized transactions whose suggested category reaches this confidence (0-1, 0 = off)")
 
 	if len(os.Args) < 2 {
-		fmt.Println("expected 'extract' or 'convert' subcommands")
+		fmt.Println("expected 'extract', 'convert', 'dupes', 'diff', 'report', 'balances', 'recurring', 'payees', 'suggest-categories', 'tax-report', 'budget', 'holdings' or 'anonymize' subcommands")
 		os.Exit(1)
 	}
 
@@ -53,76 +78,174 @@ func main() {
 		fmt.Println("	Extract Payees:", *extractPayee)
 		fmt.Println("	Extract Tags:", *extractTag)
 		fmt.Println("	Extract Accounts:", *extractAccount)
-		fmt.Println("	Source File:", *extractInputFile)
+		fmt.Println("	Accounts Format:", *extractAccountFormat)
+		fmt.Println("	Extract Memorized:", *extractMemorized)
+		fmt.Println("	Extract Memorized Rules:", *extractMemorizedRules)
+		fmt.Println("	Source File:", extractInputFile.String())
 		fmt.Println("	Args:", extractCmd.Args())
 		extractCategoryFlag = *extractCategory
 		extractPayeeFlag = *extractPayee
 		extractTagFlag = *extractTag
 		extractAccountFlag = *extractAccount
-		inputFileName = *extractInputFile
+		if *extractAccountFormat != "txt" && *extractAccountFormat != "csv" && *extractAccountFormat != "json" {
+			fmt.Println("unknown accounts format:", *extractAccountFormat, "(expected txt, csv or json)")
+			os.Exit(1)
+		}
+		extractAccountFormatValue = *extractAccountFormat
+		extractMemorizedFlag = *extractMemorized
+		extractMemorizedRulesFlag = *extractMemorizedRules
+		inputFileNames = extractInputFile
 	case "convert":
 		convertCmd.Parse(os.Args[2:])
 		fmt.Println("subcommand 'convert'")
-		fmt.Println("	inputfile:", *convertInputFile)
+		fmt.Println("	inputfile:", convertInputFile.String())
 		fmt.Println("	accountname:", *convertAccountName)
 		fmt.Println("	outputfile:", *convertOutputFile)
 		fmt.Println("	applycategorymap:", *convertCategoryMapFile)
 		fmt.Println("	applypayeemap:", *convertPayeeMapFile)
 		fmt.Println("	applyaccountmap:", *convertAccountMapFile)
+		fmt.Println("	applypayeerules:", *convertPayeeRulesFile)
+		fmt.Println("	invertsigns:", *convertInvertSigns)
+		fmt.Println("	amountstyle:", *convertAmountStyle)
+		fmt.Println("	dateformat:", *convertDateFormat)
+		fmt.Println("	from:", *convertFrom)
+		fmt.Println("	to:", *convertTo)
+		fmt.Println("	accounts:", *convertAccounts)
+		fmt.Println("	exclude-accounts:", *convertExcludeAccounts)
+		fmt.Println("	min-amount:", *convertMinAmount)
+		fmt.Println("	max-amount:", *convertMaxAmount)
+		fmt.Println("	cleared-only:", *convertClearedOnly)
+		fmt.Println("	payee-match:", *convertPayeeMatch)
+		fmt.Println("	since-state:", *convertStateFile)
+		fmt.Println("	dedupe:", *convertDedupe)
+		fmt.Println("	dedupe-across-accounts:", *convertDedupeAcross)
+		fmt.Println("	dedupe-review:", *convertDedupeReview)
+		fmt.Println("	format:", *convertFormat)
+		fmt.Println("	bankid:", *convertBankID)
+		fmt.Println("	suggest-categories:", *convertSuggestCategories)
 		//fmt.Println("	tail:", convertCmd.Args())
 		//accountName = *convertAccountName
-		inputFileName = *convertInputFile
-		outputFileName = *convertOutputFile
-		categoryMappingFile = *convertCategoryMapFile
-		payeeMappingFile = *convertPayeeMapFile
-		accountMappingFile = *convertAccountMapFile
+		convertOpts.InputFileNames = convertInputFile
+		convertOpts.OutputFileName = *convertOutputFile
+		convertOpts.CategoryMappingFile = *convertCategoryMapFile
+		convertOpts.PayeeMappingFile = *convertPayeeMapFile
+		convertOpts.AccountMappingFile = *convertAccountMapFile
+		convertOpts.PayeeRulesFile = *convertPayeeRulesFile
+		convertOpts.InvertSigns = splitList(*convertInvertSigns)
+		convertOpts.AmountStyle = *convertAmountStyle
+		convertOpts.DateFormat = *convertDateFormat
+		filter, err := newTransactionFilter(*convertFrom, *convertTo, *convertAccounts, *convertExcludeAccounts, *convertMinAmount, *convertMaxAmount, *convertClearedOnly, *convertPayeeMatch)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		convertOpts.Filter = filter
+		convertOpts.StateFile = *convertStateFile
+		convertOpts.StateGraceDays = *convertStateGraceDays
+		if *convertDedupe != "" && *convertDedupe != "exact" && *convertDedupe != "fuzzy" {
+			fmt.Println("unknown dedupe mode:", *convertDedupe, "(expected exact or fuzzy)")
+			os.Exit(1)
+		}
+		convertOpts.Dedupe = *convertDedupe
+		convertOpts.DedupeDays = *convertDedupeDays
+		convertOpts.DedupeAcrossAccounts = *convertDedupeAcross
+		convertOpts.DedupeReviewFile = *convertDedupeReview
+		convertOpts.Format = *convertFormat
+		convertOpts.LedgerMappingFile = *convertLedgerMapFile
+		convertOpts.BankID = *convertBankID
+		convertOpts.SuggestCategories = *convertSuggestCategories
+	case "dupes":
+		runDupes(os.Args[2:])
+	case "diff":
+		runDiff(os.Args[2:])
+	case "report":
+		runReport(os.Args[2:])
+	case "balances":
+		runBalances(os.Args[2:])
+	case "recurring":
+		runRecurring(os.Args[2:])
+	case "payees":
+		runPayees(os.Args[2:])
+	case "suggest-categories":
+		runSuggestCategories(os.Args[2:])
+	case "tax-report":
+		runTaxReport(os.Args[2:])
+	case "budget":
+		runBudget(os.Args[2:])
+	case "holdings":
+		runHoldings(os.Args[2:])
+	case "anonymize":
+		runAnonymize(os.Args[2:])
 	default:
-		fmt.Println("expected 'extract' or 'convert' subcommands")
+		fmt.Println("expected 'extract', 'convert', 'dupes', 'diff', 'report', 'balances', 'recurring', 'payees', 'suggest-categories', 'tax-report', 'budget', 'holdings' or 'anonymize' subcommands")
 		os.Exit(1)
 	}
 
 	if os.Args[1] == "extract" {
 		if extractCategoryFlag {
-			err := extractCategories(inputFileName, "categoryList.txt")
+			err := extractCategories(inputFileNames, "categoryList.txt")
 			if err != nil {
 				fmt.Println("Error with category extraction: ", err)
 			}
 		}
 		if extractPayeeFlag {
-			err := extractPayees(inputFileName, "payeeList.txt")
+			err := extractPayees(inputFileNames, "payeeList.txt")
 			if err != nil {
 				fmt.Println("Error with payee extraction: ", err)
 			}
 		}
 		if extractTagFlag {
-			err := extractTags(inputFileName, "tagsList.txt")
+			err := extractTags(inputFileNames, "tagsList.txt")
 			if err != nil {
 				fmt.Println("Error with tag extraction: ", err)
 			}
 		}
 		if extractAccountFlag {
-			err := extractAccounts(inputFileName, "AccountsList.txt")
+			var err error
+			if extractAccountFormatValue == "txt" {
+				err = extractAccounts(inputFileNames, "AccountsList.txt")
+			} else {
+				err = extractAccountReport(inputFileNames, "AccountsList."+extractAccountFormatValue, extractAccountFormatValue)
+			}
 			if err != nil {
 				fmt.Println("Error with account extraction: ", err)
 			}
 		}
+		if extractMemorizedFlag {
+			err := extractMemorizedTransactions(inputFileNames, "memorizedList.csv")
+			if err != nil {
+				fmt.Println("Error with memorized extraction: ", err)
+			}
+		}
+		if extractMemorizedRulesFlag {
+			err := extractPayeeRules(inputFileNames, "payeeRules.txt")
+			if err != nil {
+				fmt.Println("Error with memorized rules extraction: ", err)
+			}
+		}
 	}
 
 	if os.Args[1] == "convert" {
-		exportTransactions(inputFileName, outputFileName, categoryMappingFile, payeeMappingFile, accountMappingFile)
+		exportTransactions(convertOpts)
 	}
 }
 
-func exportTransactions(inputFileName string, outputFileName string, categoryMappingFile string, payeeMappingFile string, accountMappingFile string) {
-	// Output CSV Header
-	var transactionRegexString string = `D(?<month>\d{1,2})\/(\s?(?<day>\d{1,2}))'(?<year>\d{2})[\r\n]+(U(?<amount1>.*?)[\r\n]+)(T(?<amount2>.*?)[\r\n]+)(C(?<cleared>.*?)[\r\n]+)((N(?<number>.*?)[\r\n]+)?)(P(?<payee>.*?)[\r\n]+)((M(?<memo>.*?)[\r\n]+)?)(L(?<category>.*?)[\r\n]+)`
-	var accountBlockHeaderRegex string = `(?m)^!Account[^\n]*\n^N(.*?)\n^T(.*?)\n^\^\n^!Type:(Bank|CCard)\s*\n`
-	outputCSVHeader := "Date,Merchant,Category,Account,Original Statement,Notes,Amount,Tags\n"
+func exportTransactions(options convertOptions) {
 	var categoryMapping map[string]string
 	var payeeMapping map[string]string
 	var accountMapping map[string]string
+	var payeeRules map[string]string
 	var err error
 
+	if err := checkAmountStyle(options.AmountStyle); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := checkOutputFormat(options.Format); err != nil {
+		fmt.Println(err)
+		return
+	}
+
 	//// Create the output file.
 	//outputFile, err := os.Create(outputFileName)
 	//if err != nil {
@@ -139,8 +262,8 @@ func exportTransactions(inputFileName string, outputFileName string, categoryMap
 	//}
 
 	// Load the Category Mapping
-	if categoryMappingFile != "" {
-		categoryMapping, err = loadMapping(categoryMappingFile)
+	if options.CategoryMappingFile != "" {
+		categoryMapping, err = loadMapping(options.CategoryMappingFile)
 		if err != nil {
 			fmt.Println("Error loading mapping:", err)
 			return
@@ -154,8 +277,8 @@ func exportTransactions(inputFileName string, outputFileName string, categoryMap
 	}
 
 	// Load the Payee Mapping
-	if payeeMappingFile != "" {
-		payeeMapping, err := loadMapping(payeeMappingFile)
+	if options.PayeeMappingFile != "" {
+		payeeMapping, err = loadMapping(options.PayeeMappingFile)
 		if err != nil {
 			fmt.Println("Error loading mapping:", err)
 			return
@@ -169,8 +292,8 @@ func exportTransactions(inputFileName string, outputFileName string, categoryMap
 	}
 
 	// Load the Account Mapping
-	if accountMappingFile != "" {
-		accountMapping, err = loadMapping(accountMappingFile)
+	if options.AccountMappingFile != "" {
+		accountMapping, err = loadMapping(options.AccountMappingFile)
 		if err != nil {
 			fmt.Println("Error loading mapping:", err)
 			return
@@ -185,152 +308,192 @@ func exportTransactions(inputFileName string, outputFileName string, categoryMap
 		fmt.Println("No account mapping file loaded:", err)
 	}
 
-	// Open the input file and find all the Bank and CCard blocks
-	// Load input file
-	inputBytes, err := os.ReadFile(inputFileName)
+	// Load the Payee Rules
+	if options.PayeeRulesFile != "" {
+		payeeRules, err = loadMapping(options.PayeeRulesFile)
+		if err != nil {
+			fmt.Println("Error loading payee rules:", err)
+			return
+		}
+		fmt.Println("Payee rules loaded:", len(payeeRules))
+	}
+
+	// Open the input file and parse the accounts
+	qif, err := loadQIFFiles(options.InputFileNames, accountMapping)
 	if err != nil {
 		fmt.Println("Error reading file:", err)
-	} else {
-		fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
+		return
+	}
+	if len(qif.Accounts) == 0 {
+		fmt.Println("No matches found.")
 	}
-	inputContent := string(inputBytes)
 
-	// Standardize Line Endings to simplify Regex
-	inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
+	// Learn categories from the whole file before it is filtered
+	var model *categoryModel
+	if options.SuggestCategories > 0 {
+		model = trainCategoryModel(qif)
+	}
 
-	// Gather the Account Blocks
-	// Compile the regex
-	regex, err := regexp.Compile(accountBlockHeaderRegex)
+	// Apply the filters before any mapping
+	filtered := filterTransactions(qif, options.Filter)
+	fmt.Println("Transactions filtered out:", filtered)
+
+	// Remove duplicate transactions. Fuzzy matches are only removed when
+	// asked for; otherwise they are left in place for review.
+	if options.Dedupe != "" {
+		clusters := findDuplicates(qif, options.DedupeDays, options.DedupeAcrossAccounts)
+		removed := removeDuplicates(qif, clusters, options.Dedupe == "exact")
+		fmt.Println("Duplicate transactions removed:", removed)
+		if options.Dedupe == "exact" {
+			remaining := findDuplicates(qif, options.DedupeDays, options.DedupeAcrossAccounts)
+			fmt.Println("Possible duplicates left for review:", len(remaining))
+			if options.DedupeReviewFile != "" {
+				err = writeDuplicateReview(remaining, options.DedupeReviewFile)
+				if err != nil {
+					fmt.Println("Error writing duplicate review:", err)
+					return
+				}
+			}
+		}
+	}
+
+	// Skip transactions exported by a previous run
+	var state *exportState
+	noNewRows := make(map[string]bool)
+	if options.StateFile != "" {
+		state, err = loadExportState(options.StateFile)
+		if err != nil {
+			fmt.Println("Error loading state file:", err)
+			return
+		}
+		skipped := applyExportState(qif, state, options.StateGraceDays)
+		fmt.Println("Transactions previously exported:", skipped)
+		unchanged := accountsWithoutNewTransactions(qif, state)
+		if len(unchanged) > 0 {
+			fmt.Println("Accounts with no new transactions:", strings.Join(unchanged, ", "))
+		}
+		for _, name := range unchanged {
+			noNewRows[name] = true
+		}
+	}
+
+	// Apply the payee and category mappings to the parsed transactions
+	applyTransactionMappings(qif, payeeMapping, categoryMapping, payeeRules)
+
+	// Suggest categories for what the payee rules left uncategorized
+	if model != nil {
+		applied := applyCategorySuggestions(qif, model, categoryMapping, options.SuggestCategories)
+		fmt.Println("Suggested categories applied:", applied)
+	}
+
+	switch options.Format {
+	case "qif":
+		err = writeQIFFile(qif, accountMapping, options.OutputFileName)
+	case "ofx":
+		err = writeOFXFile(qif, accountMapping, options.BankID, options.OutputFileName, false)
+	case "ofx1":
+		err = writeOFXFile(qif, accountMapping, options.BankID, options.OutputFileName, true)
+	case "ledger":
+		err = writeJournalFile(qif, accountMapping, options.LedgerMappingFile, options.OutputFileName, false)
+	case "beancount":
+		err = writeJournalFile(qif, accountMapping, options.LedgerMappingFile, options.OutputFileName, true)
+	case "json":
+		err = writeJSONFile(qif, accountMapping, options.OutputFileName)
+	case "ndjson":
+		err = writeNDJSONFile(qif, accountMapping, options.OutputFileName)
+	case "sqlite":
+		err = writeSQLiteFile(qif, accountMapping, options.OutputFileName)
+	case "xlsx":
+		err = writeXLSXFile(qif, accountMapping, options.OutputFileName)
+	default:
+		err = writeCSVFiles(qif, accountMapping, options, noNewRows)
+	}
 	if err != nil {
+		fmt.Println("Error writing to file:", err)
 		return
 	}
-	accountBlocks := regex.FindAllStringSubmatchIndex(inputContent, -1)
-	if len(accountBlocks) == 0 {
-		fmt.Println("No matches found.")
+
+	if state != nil {
+		recordExportState(qif, state, options.StateGraceDays)
+		err = saveExportState(options.StateFile, state)
+		if err != nil {
+			fmt.Println("Error saving state file:", err)
+		}
 	}
+}
 
-	// loop over each account block
-	// Find all matches for transactions
-	for _, accountBlock := range accountBlocks {
+// writeCSVFiles writes one CSV file per account, named after the account
+// followed by the -outputfile value. Accounts in noNewRows get a file with just
+// the header, replacing the one written by the previous run.
+func writeCSVFiles(qif *qifFile, accountMapping map[string]string, options convertOptions, noNewRows map[string]bool) error {
+	// Output CSV Header
+	outputCSVHeader := "Date,Merchant,Category,Account,Original Statement,Notes," + amountHeader(options.AmountStyle) + ",Tags\n"
+	// With several input files each row records the file it came from
+	multipleSources := len(options.InputFileNames) > 1
+	if multipleSources {
+		outputCSVHeader = strings.TrimSuffix(outputCSVHeader, "\n") + ",Source\n"
+	}
+
+	// loop over each account
+	for _, account := range qif.Accounts {
+		if len(account.Transactions) == 0 && !noNewRows[account.Name] {
+			continue
+		}
+		invertSigns := matchesAccount(account, options.InvertSigns)
 		var outputAccountName string
-		accountName := inputContent[accountBlock[2]:accountBlock[3]]
+		accountName := account.Name
 		if len(accountMapping[accountName]) > 0 {
 			outputAccountName = accountMapping[accountName]
 		} else {
 			outputAccountName = accountName
 		}
 
-		restOfText := inputContent[accountBlock[1]:]
-		nextTypePattern := `(?mi)^\s*!Type:.*$`
-		nextTypeRe := regexp.MustCompile(nextTypePattern)
-		nextLoc := nextTypeRe.FindStringIndex(restOfText)
-		var endPos int
-		if nextLoc != nil {
-			endPos = accountBlock[1] + nextLoc[0]
-		} else {
-			endPos = len(inputContent)
-		}
-		//fmt.Printf("%d, %d\n", accountBlock[1], nextLoc[0])
-
 		// Create unique output file per Account
-		outputFile, err := os.Create(accountName + outputFileName)
+		outputFile, err := os.Create(accountName + options.OutputFileName)
 		if err != nil {
-			fmt.Println("Error creating file:", err)
-			return
+			return err
 		}
 		// Write header to the output file.
 		_, err = outputFile.WriteString(outputCSVHeader)
 		if err != nil {
-			fmt.Println("Error writing to file:", err)
-			return
+			outputFile.Close()
+			return err
 		}
-		//defer outputFile.Close()
 
-		// Extract the text between the type lines
-		textBetweenTypes := inputContent[accountBlock[1]:endPos]
+		for _, t := range account.Transactions {
+			payee := strings.TrimSpace(t.Payee)
+			transactionMemo := strings.TrimSpace(t.Memo)
+			category, tag := splitCategoryAndTag(t.Category)
 
-		// Use the existing pattern to match entries
-		regex, err := regexp.Compile(transactionRegexString)
-		if err != nil {
-			return
-		}
+			// DATE
+			fullDate := prepareString(formatDate(t.Date, options.DateFormat))
 
-		// Find all transactions in the content.
-		transactions := regex.FindAllStringSubmatch(textBetweenTypes, -1)
-
-		for _, t := range transactions {
-			// Check if there is a captured group and extract the content.
-			if len(t) > 1 { // Ensure there is a captured group.
-				//fmt.Println("Transaction:")
-
-				month := strings.TrimSpace(t[1])
-				day := strings.TrimSpace(t[2])
-				year := strings.TrimSpace(t[4])
-				amount1 := strings.TrimSpace(t[6])
-				amount2 := strings.TrimSpace(t[8])
-				cleared := strings.TrimSpace(t[10])
-				number := strings.TrimSpace(t[13])
-				payee := strings.TrimSpace(t[15])
-				transactionMemo := strings.TrimSpace(t[18])
-				category, tag := splitCategoryAndTag(t[20])
-
-				if len(payeeMapping) > 0 {
-					payee = applyMapping(payee, payeeMapping)
-				}
-				if len(categoryMapping) > 0 {
-					category = applyMapping(category, categoryMapping)
-				}
-
-				// DATE
-				fullYear := "20" + year
-				month = "0" + month
-				fullMonth := month[len(month)-2:]
-				day = "0" + day
-				fullDay := day[len(day)-2:]
-				fullDate := fullYear + "-" + fullMonth + "-" + fullDay
-
-				amount1 = prepareString(amount1)
-				amount2 = prepareString(amount2)
-				cleared = prepareString(cleared)
-				number = prepareString(number)
-				payee = prepareString(payee)
-				transactionMemo = prepareString(transactionMemo)
-				category = prepareString(category)
-				tag = prepareString(tag)
-
-				//fmt.Printf("  Date: %s-%s-%s\n", fullMonth, fullDay, fullYear)
-				//fmt.Printf("  Payee: %s\n", payee)
-				//fmt.Printf("  Category: %s\n", category)
-				//fmt.Printf("  AccountName: %s\n", outputAccountName)
-				//fmt.Printf("  Amount: %s\n", amount1)
-				//fmt.Printf("  Amount2: %s\n", amount2)
-				//if transactionMemo != "" {
-				//	fmt.Printf("  Transaction Memo: %s\n", transactionMemo)
-				//}
-				//if number != "" {
-				//	fmt.Printf("  Transaction Number: %s\n", number)
-				//}
-				//if cleared != "" {
-				//	fmt.Printf("  Cleared: %s\n", cleared)
-				//}
-				//if tag != "" {
-				//	fmt.Printf("  Tag: %s\n", tag)
-				//}
-
-				_, err := outputFile.WriteString(fullDate + "," + payee + "," + category + "," + outputAccountName + "," + payee + "," + transactionMemo + "," + amount1 + "," + tag + "\n")
+			amount := t.Amount
+			if invertSigns {
+				amount = -amount
+			}
+			payee = prepareString(payee)
+			transactionMemo = prepareString(transactionMemo)
+			category = prepareString(category)
+			tag = prepareString(tag)
+
+			row := fullDate + "," + payee + "," + category + "," + outputAccountName + "," + payee + "," + transactionMemo + "," + amountColumns(amount, options.AmountStyle) + "," + tag
+			if multipleSources {
+				row += "," + prepareString(t.Source)
+			}
+			_, err := outputFile.WriteString(row + "\n")
 
-				if err != nil {
-					fmt.Println("Error writing to file:", err)
-					return
-				}
+			if err != nil {
+				outputFile.Close()
+				return err
 			}
 		}
 		outputFile.Close()
 	}
-
+	return nil
 }
 
-func extractPayees(inputFileName string, outputFileName string) error {
+func extractPayees(inputFileNames []string, outputFileName string) error {
 	// Changing up the process
 	// Each task will have all processes within it to make parameter adjustments easier
 	// 1. Get input file
@@ -351,14 +514,8 @@ func extractPayees(inputFileName string, outputFileName string) error {
 	}
 	defer payeeFile.Close()
 
-	// Load input file
-	inputBytes, err := os.ReadFile(inputFileName)
-	if err != nil {
-		fmt.Println("Error reading file:", err)
-	} else {
-		fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
-	}
-	inputContent := string(inputBytes)
+	// Load input files
+	inputContent := readInputFiles(inputFileNames)
 
 	// Standardize Line Endings to simplify Regex
 	inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
@@ -427,7 +584,7 @@ func extractPayees(inputFileName string, outputFileName string) error {
 	return nil
 }
 
-func extractAccounts(inputFileName string, outputFileName string) error {
+func extractAccounts(inputFileNames []string, outputFileName string) error {
 	// Changing up the process
 	// Each task will have all processes within it to make parameter adjustments easier
 	// 1. Get input file
@@ -447,14 +604,8 @@ func extractAccounts(inputFileName string, outputFileName string) error {
 	}
 	defer accountFile.Close()
 
-	// Load input file
-	inputBytes, err := os.ReadFile(inputFileName)
-	if err != nil {
-		fmt.Println("Error reading file:", err)
-	} else {
-		fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
-	}
-	inputContent := string(inputBytes)
+	// Load input files
+	inputContent := readInputFiles(inputFileNames)
 
 	// Standardize Line Endings to simplify Regex
 	inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
@@ -493,7 +644,7 @@ func extractAccounts(inputFileName string, outputFileName string) error {
 	return nil
 }
 
-func extractCategories(inputFileName string, outputFileName string) error {
+func extractCategories(inputFileNames []string, outputFileName string) error {
 	// Changing up the process
 	// Each task will have all processes within it to make parameter adjustments easier
 	// 1. Get input file
@@ -517,14 +668,8 @@ func extractCategories(inputFileName string, outputFileName string) error {
 	}
 	defer categoryFile.Close()
 
-	// Load input file
-	inputBytes, err := os.ReadFile(inputFileName)
-	if err != nil {
-		fmt.Println("Error reading file:", err)
-	} else {
-		fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
-	}
-	inputContent := string(inputBytes)
+	// Load input files
+	inputContent := readInputFiles(inputFileNames)
 
 	// Standardize Line Endings to simplify Regex
 	inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
@@ -535,50 +680,52 @@ func extractCategories(inputFileName string, outputFileName string) error {
 	if err != nil {
 		fmt.Println("Error compiling regular expression: ", err)
 	}
-	loc := catTypeRe.FindStringIndex(inputContent)
-	if loc == nil {
+	// Each input file can carry its own Category block
+	locs := catTypeRe.FindAllStringIndex(inputContent, -1)
+	if len(locs) == 0 {
 		fmt.Printf("No Category block found.\n")
 		return nil
-	} else {
+	}
+	var regex *regexp.Regexp
+	for _, loc := range locs {
 		// Debugging output
 		fmt.Printf("Category block found at position: %d\n", loc[1])
-	}
 
-	// Find the position of the next Type block
-	restOfText := inputContent[loc[1]:]
-	nextTypePattern := `(?mi)^\s*!Type:.*$`
-	nextTypeRe := regexp.MustCompile(nextTypePattern)
-	nextLoc := nextTypeRe.FindStringIndex(restOfText)
-	fmt.Printf("Next type found at:%d\n", nextLoc[1])
-	var endPos int
-	if nextLoc != nil {
-		// Found another Type line.
-		endPos = loc[1] + nextLoc[0]
-	} else {
-		// No other Type found
-		endPos = len(inputContent)
-	}
+		// Find the position of the next Type block
+		restOfText := inputContent[loc[1]:]
+		nextTypePattern := `(?mi)^\s*!Type:.*$`
+		nextTypeRe := regexp.MustCompile(nextTypePattern)
+		nextLoc := nextTypeRe.FindStringIndex(restOfText)
+		var endPos int
+		if nextLoc != nil {
+			// Found another Type line.
+			endPos = loc[1] + nextLoc[0]
+		} else {
+			// No other Type found
+			endPos = len(inputContent)
+		}
 
-	// Extract the text between the Type lines
-	textBetweenTypes := inputContent[loc[1]:endPos]
+		// Extract the text between the Type lines
+		textBetweenTypes := inputContent[loc[1]:endPos]
 
-	// Use the existing pattern to match entries
-	regex, err := regexp.Compile(catRecordRegex)
-	if err != nil {
-		return err
-	}
+		// Use the existing pattern to match entries
+		regex, err = regexp.Compile(catRecordRegex)
+		if err != nil {
+			return err
+		}
 
-	// Find all matches in the content.
-	matches := regex.FindAllStringSubmatch(textBetweenTypes, -1)
-	fmt.Printf("%d entries extracted from the category block.\n", len(matches))
+		// Find all matches in the content.
+		matches := regex.FindAllStringSubmatch(textBetweenTypes, -1)
+		fmt.Printf("%d entries extracted from the category block.\n", len(matches))
 
-	// Extract patterns to array from the Category block.
-	for _, t := range matches {
-		// Check if there is a captured group and extract the content.
-		if len(t) > 1 {
-			// Ensure there is a captured group.
-			category := strings.TrimSpace(t[2])
-			categories = append(categories, category)
+		// Extract patterns to array from the Category block.
+		for _, t := range matches {
+			// Check if there is a captured group and extract the content.
+			if len(t) > 1 {
+				// Ensure there is a captured group.
+				category := strings.TrimSpace(t[2])
+				categories = append(categories, category)
+			}
 		}
 	}
 
@@ -646,7 +793,7 @@ func extractCategories(inputFileName string, outputFileName string) error {
 	return nil
 }
 
-func extractTags(inputFileName string, outputFileName string) error {
+func extractTags(inputFileNames []string, outputFileName string) error {
 	// Changing up the process
 	// Each task will have all processes within it to make parameter adjustments easier
 	// 1. Get input file
@@ -670,14 +817,8 @@ func extractTags(inputFileName string, outputFileName string) error {
 	}
 	defer tagFile.Close()
 
-	// Load input file
-	inputBytes, err := os.ReadFile(inputFileName)
-	if err != nil {
-		fmt.Println("Error reading file:", err)
-	} else {
-		fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
-	}
-	inputContent := string(inputBytes)
+	// Load input files
+	inputContent := readInputFiles(inputFileNames)
 
 	// Standardize Line Endings to simplify Regex
 	inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
@@ -687,50 +828,52 @@ func extractTags(inputFileName string, outputFileName string) error {
 	if err != nil {
 		fmt.Println("Error compiling regular expression: ", err)
 	}
-	loc := tagTypeRe.FindStringIndex(inputContent)
-	if loc == nil {
+	// Each input file can carry its own Tag block
+	locs := tagTypeRe.FindAllStringIndex(inputContent, -1)
+	if len(locs) == 0 {
 		fmt.Printf("No Tag block found.\n")
 		return nil
-	} else {
+	}
+	var regex *regexp.Regexp
+	for _, loc := range locs {
 		// Debugging output
 		fmt.Printf("Tag block found at position: %d\n", loc[1])
-	}
 
-	// Find the position of the next Type block
-	restOfText := inputContent[loc[1]:]
-	nextTypePattern := `(?mi)^\s*!Type:.*$`
-	nextTypeRe := regexp.MustCompile(nextTypePattern)
-	nextLoc := nextTypeRe.FindStringIndex(restOfText)
-	fmt.Printf("Next type found at:%d\n", nextLoc[1])
-	var endPos int
-	if nextLoc != nil {
-		// Found another Type line.
-		endPos = loc[1] + nextLoc[0]
-	} else {
-		// No other Type found
-		endPos = len(inputContent)
-	}
+		// Find the position of the next Type block
+		restOfText := inputContent[loc[1]:]
+		nextTypePattern := `(?mi)^\s*!Type:.*$`
+		nextTypeRe := regexp.MustCompile(nextTypePattern)
+		nextLoc := nextTypeRe.FindStringIndex(restOfText)
+		var endPos int
+		if nextLoc != nil {
+			// Found another Type line.
+			endPos = loc[1] + nextLoc[0]
+		} else {
+			// No other Type found
+			endPos = len(inputContent)
+		}
 
-	// Extract the text between the Type lines
-	textBetweenTypes := inputContent[loc[1]:endPos]
+		// Extract the text between the Type lines
+		textBetweenTypes := inputContent[loc[1]:endPos]
 
-	// Use the existing pattern to match entries
-	regex, err := regexp.Compile(tagRecordRegex)
-	if err != nil {
-		return err
-	}
+		// Use the existing pattern to match entries
+		regex, err = regexp.Compile(tagRecordRegex)
+		if err != nil {
+			return err
+		}
 
-	// Find all matches in the content.
-	matches := regex.FindAllStringSubmatch(textBetweenTypes, -1)
-	fmt.Printf("%d entries extracted from the tag block.\n", len(matches))
+		// Find all matches in the content.
+		matches := regex.FindAllStringSubmatch(textBetweenTypes, -1)
+		fmt.Printf("%d entries extracted from the tag block.\n", len(matches))
 
-	// Extract patterns to array from the Tag block.
-	for _, t := range matches {
-		// Check if there is a captured group and extract the content.
-		if len(t) > 1 {
-			// Ensure there is a captured group.
-			tag := strings.TrimSpace(t[2])
-			tags = append(tags, tag)
+		// Extract patterns to array from the Tag block.
+		for _, t := range matches {
+			// Check if there is a captured group and extract the content.
+			if len(t) > 1 {
+				// Ensure there is a captured group.
+				tag := strings.TrimSpace(t[2])
+				tags = append(tags, tag)
+			}
 		}
 	}
 
@@ -821,8 +964,12 @@ func splitCategoryAndTag(originalCategoryValue string) (category string, tag str
 	}
 
 	if len(nonEmptyParts) == 1 {
-		// Returnm the last element and the rest of the string
-		rest = strings.Join(nonEmptyParts[:len(nonEmptyParts)-1], "/")
+		// A single value is the category unless it follows the separator
+		if strings.HasPrefix(originalCategoryValue, "/") {
+			lastItem = nonEmptyParts[0]
+		} else {
+			rest = nonEmptyParts[0]
+		}
 	} else {
 		lastItem = nonEmptyParts[len(nonEmptyParts)-1]
 		rest = strings.Join(nonEmptyParts[:len(nonEmptyParts)-1], "/")
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestSplitCategoryAndTag(t *testing.T) {
+	tests := []struct {
+		value    string
+		category string
+		tag      string
+	}{
+		{"", "", ""},
+		// A plain category has no tag
+		{"Groceries", "Groceries", ""},
+		{"Auto:Fuel", "Auto:Fuel", ""},
+		{"Groceries/Vacation", "Groceries", "Vacation"},
+		{"/Vacation", "", "Vacation"},
+		{"Groceries/", "Groceries", ""},
+		{"[Visa]/Business", "[Visa]", "Business"},
+		{"Home/Repairs/Rental", "Home/Repairs", "Rental"},
+	}
+	for _, test := range tests {
+		category, tag := splitCategoryAndTag(test.value)
+		if category != test.category || tag != test.tag {
+			t.Errorf("splitCategoryAndTag(%q) = %q, %q, want %q, %q", test.value, category, tag, test.category, test.tag)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// memorizedKinds names the K line values of a memorized transaction.
+var memorizedKinds = map[string]string{
+	"C": "Check",
+	"D": "Deposit",
+	"P": "Payment",
+	"I": "Investment",
+	"E": "Electronic",
+}
+
+// extractMemorizedTransactions writes the memorized transactions to a CSV
+// file. Split memorized transactions get one row per split with the split
+// columns filled.
+func extractMemorizedTransactions(inputFileNames []string, outputFileName string) error {
+	qif, err := loadQIFFiles(inputFileNames, nil)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return err
+	}
+
+	memorizedFile, err := os.Create(outputFileName)
+	if err != nil {
+		fmt.Println("Error creating memorized file:", err)
+		return err
+	} else {
+		fmt.Println("Created memorized output file.")
+	}
+	defer memorizedFile.Close()
+
+	writer := csv.NewWriter(memorizedFile)
+	writer.Write([]string{"Payee", "Category", "Memo", "Amount", "Kind", "Split Category", "Split Memo", "Split Amount"})
+	for _, m := range qif.Memorized {
+		kind := memorizedKinds[m.Kind]
+		if kind == "" {
+			kind = m.Kind
+		}
+		row := []string{m.Payee, m.Category, m.Memo, m.Amount.String(), kind}
+		if len(m.Splits) == 0 {
+			writer.Write(append(row, "", "", ""))
+			continue
+		}
+		for _, split := range m.Splits {
+			writer.Write(append(row, split.Category, split.Memo, split.Amount.String()))
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+
+	fmt.Println("Extracted Memorized Transactions: ", len(qif.Memorized))
+
+	return nil
+}
+
+// extractPayeeRules writes the memorized payees as a payee,category rules file
+// for convert's -payeerules option. Payees whose memorized transaction has no
+// category are skipped; split memorized transactions use the first split's
+// category.
+func extractPayeeRules(inputFileNames []string, outputFileName string) error {
+	qif, err := loadQIFFiles(inputFileNames, nil)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return err
+	}
+
+	rules := make(map[string]string)
+	for _, m := range qif.Memorized {
+		payee := prepareString(m.Payee)
+		category, _ := splitCategoryAndTag(m.Category)
+		if category == "" && len(m.Splits) > 0 {
+			category, _ = splitCategoryAndTag(m.Splits[0].Category)
+		}
+		category = prepareString(category)
+		if payee == "" || category == "" {
+			continue
+		}
+		rules[payee] = category
+	}
+
+	var payees []string
+	for payee := range rules {
+		payees = append(payees, payee)
+	}
+	sort.Strings(payees)
+
+	rulesFile, err := os.Create(outputFileName)
+	if err != nil {
+		fmt.Println("Error creating rules file:", err)
+		return err
+	}
+	defer rulesFile.Close()
+
+	for _, payee := range payees {
+		_, err := rulesFile.WriteString(payee + "," + rules[payee] + "\n")
+		if err != nil {
+			fmt.Printf("Error Writing to rules file:\n")
+		}
+	}
+
+	fmt.Println("Extracted Payee Rules: ", len(payees))
+
+	return nil
+}
+
+// applyPayeeRules returns the category for an uncategorized transaction from
+// the payee rules, matching the payee case-insensitively. A rule with the
+// payee's exact spelling wins; otherwise rules that differ only in case are
+// tried in sorted order, so the same rule is picked on every run.
+func applyPayeeRules(payee string, category string, rules map[string]string) string {
+	if category != "" {
+		return category
+	}
+	if ruleCategory, ok := rules[payee]; ok {
+		return ruleCategory
+	}
+	rulePayees := make([]string, 0, len(rules))
+	for rulePayee := range rules {
+		rulePayees = append(rulePayees, rulePayee)
+	}
+	sort.Strings(rulePayees)
+	for _, rulePayee := range rulePayees {
+		if strings.EqualFold(rulePayee, payee) {
+			return rules[rulePayee]
+		}
+	}
+	return category
+}
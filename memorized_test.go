@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestApplyPayeeRules(t *testing.T) {
+	rules := map[string]string{
+		"ACME CORP": "Income:Other",
+		"Acme Corp": "Salary",
+		"acme corp": "Bonus",
+		"Costco":    "Groceries",
+	}
+	tests := []struct {
+		payee    string
+		category string
+		want     string
+	}{
+		// An existing category is never replaced
+		{"Costco", "Household", "Household"},
+		{"Costco", "", "Groceries"},
+		{"COSTCO", "", "Groceries"},
+		// The exact spelling wins over rules that differ only in case
+		{"Acme Corp", "", "Salary"},
+		{"acme corp", "", "Bonus"},
+		// Otherwise the first rule in sorted order
+		{"Acme CORP", "", "Income:Other"},
+		{"Unknown", "", ""},
+	}
+	for _, test := range tests {
+		// Map iteration order varies, so repeat to catch a random pick
+		for i := 0; i < 20; i++ {
+			if got := applyPayeeRules(test.payee, test.category, rules); got != test.want {
+				t.Fatalf("applyPayeeRules(%q, %q) = %q, want %q", test.payee, test.category, got, test.want)
+			}
+		}
+	}
+}
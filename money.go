@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// money is an exact currency amount stored as a count of cents. Totals,
+// splits and balances are summed as integers so they never pick up float
+// rounding.
+type money int64
+
+// parseMoney reads a QIF amount. It accepts thousands separators, a leading
+// "+" or "-", a trailing "-" and accounting style parentheses. Amounts with
+// more than two significant decimal places are rejected rather than rounded.
+func parseMoney(value string) (money, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, nil
+	}
+
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	if strings.HasSuffix(s, "-") {
+		negative = !negative
+		s = strings.TrimSpace(s[:len(s)-1])
+	}
+	if strings.HasPrefix(s, "-") {
+		negative = !negative
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "$")
+	s = strings.ReplaceAll(s, ",", "")
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" {
+		whole = "0"
+	}
+	// Drop trailing zeros so "1.500" is still exact
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > 2 {
+		return 0, fmt.Errorf("too many decimal places in amount: %s", value)
+	}
+	for len(fraction) < 2 {
+		fraction += "0"
+	}
+	if strings.ContainsAny(whole+fraction, "+-") {
+		return 0, fmt.Errorf("invalid amount: %s", value)
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", value)
+	}
+	cents, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", value)
+	}
+
+	m := money(units*100 + cents)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// String formats the amount as a plain signed decimal, e.g. "-1234.56".
+func (m money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+	}
+	abs := m.Abs()
+	return fmt.Sprintf("%s%d.%02d", sign, int64(abs)/100, int64(abs)%100)
+}
+
+// Abs returns the amount without its sign.
+func (m money) Abs() money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// float returns the amount in dollars for outputs with a numeric type, such
+// as spreadsheet cells.
+func (m money) float() float64 {
+	return float64(m) / 100
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    money
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"45.20", 4520, false},
+		{"-45.20", -4520, false},
+		{"+45.20", 4520, false},
+		{" 45.2 ", 4520, false},
+		{"45", 4500, false},
+		{".99", 99, false},
+		{"1,234.56", 123456, false},
+		{"-1,234,567.89", -123456789, false},
+		{"$12.00", 1200, false},
+		{"-$12.00", -1200, false},
+		{"(12.34)", -1234, false},
+		{"($1,000.00)", -100000, false},
+		{"12.34-", -1234, false},
+		{"(12.34-)", 1234, false},
+		{"1.500", 150, false},
+		{"1.505", 0, true},
+		{"0.001", 0, true},
+		{"abc", 0, true},
+		{"12.3x", 0, true},
+		{"--12", 0, true},
+		{"1.2.3", 0, true},
+	}
+	for _, test := range tests {
+		got, err := parseMoney(test.value)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseMoney(%q) = %s, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMoney(%q) returned error %v", test.value, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseMoney(%q) = %d, want %d", test.value, got, test.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		amount money
+		want   string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-5, "-0.05"},
+		{4520, "45.20"},
+		{-123456, "-1234.56"},
+	}
+	for _, test := range tests {
+		if got := test.amount.String(); got != test.want {
+			t.Errorf("money(%d).String() = %q, want %q", int64(test.amount), got, test.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ofxWriter emits OFX aggregates either as XML (OFX 2.x) or as SGML (OFX
+// 1.x), where leaf elements have no closing tag.
+type ofxWriter struct {
+	b    strings.Builder
+	sgml bool
+}
+
+func (w *ofxWriter) open(tag string) {
+	w.b.WriteString("<" + tag + ">\n")
+}
+
+func (w *ofxWriter) close(tag string) {
+	w.b.WriteString("</" + tag + ">\n")
+}
+
+func (w *ofxWriter) leaf(tag string, value string) {
+	w.b.WriteString("<" + tag + ">" + ofxEscape(value))
+	if !w.sgml {
+		w.b.WriteString("</" + tag + ">")
+	}
+	w.b.WriteString("\n")
+}
+
+func ofxEscape(value string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(value)
+}
+
+// writeOFXFile writes the Bank accounts as bank statements and the CCard
+// accounts as credit card statements. FITIDs come from the same transaction
+// fingerprint -since-state uses, so they stay stable between exports. QIF
+// does not tell checking from savings accounts, so every Bank account is
+// written as CHECKING, with bankID as its routing number.
+func writeOFXFile(qif *qifFile, accountMapping map[string]string, bankID string, outputFileName string, sgml bool) error {
+	w := &ofxWriter{sgml: sgml}
+	now := time.Now().Format("20060102150405")
+
+	if sgml {
+		// Payees and memos are written as read, so the file is declared
+		// UTF-8, which OFX allows from version 1.0.3
+		w.b.WriteString("OFXHEADER:100\nDATA:OFXSGML\nVERSION:103\nSECURITY:NONE\nENCODING:UTF-8\nCHARSET:NONE\nCOMPRESSION:NONE\nOLDFILEUID:NONE\nNEWFILEUID:NONE\n\n")
+	} else {
+		w.b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
+		w.b.WriteString("<?OFX OFXHEADER=\"200\" VERSION=\"211\" SECURITY=\"NONE\" OLDFILEUID=\"NONE\" NEWFILEUID=\"NONE\"?>\n")
+	}
+
+	w.open("OFX")
+	w.open("SIGNONMSGSRSV1")
+	w.open("SONRS")
+	writeOFXStatus(w)
+	w.leaf("DTSERVER", now)
+	w.leaf("LANGUAGE", "ENG")
+	w.close("SONRS")
+	w.close("SIGNONMSGSRSV1")
+
+	var bankAccounts, cardAccounts []*qifAccount
+	for _, account := range qif.Accounts {
+		switch {
+		case len(account.Transactions) == 0:
+		case account.Type == "Bank":
+			bankAccounts = append(bankAccounts, account)
+		case account.Type == "CCard":
+			cardAccounts = append(cardAccounts, account)
+		default:
+			fmt.Printf("Skipping %s account %s: OFX output covers Bank and CCard accounts\n", account.Type, account.Name)
+		}
+	}
+
+	trnuid := 0
+	if len(bankAccounts) > 0 {
+		w.open("BANKMSGSRSV1")
+		for _, account := range bankAccounts {
+			trnuid++
+			w.open("STMTTRNRS")
+			w.leaf("TRNUID", fmt.Sprint(trnuid))
+			writeOFXStatus(w)
+			w.open("STMTRS")
+			w.leaf("CURDEF", "USD")
+			w.open("BANKACCTFROM")
+			w.leaf("BANKID", bankID)
+			w.leaf("ACCTID", ofxAccountName(account.Name, accountMapping))
+			w.leaf("ACCTTYPE", "CHECKING")
+			w.close("BANKACCTFROM")
+			writeOFXTransactions(w, account)
+			w.close("STMTRS")
+			w.close("STMTTRNRS")
+		}
+		w.close("BANKMSGSRSV1")
+	}
+
+	if len(cardAccounts) > 0 {
+		w.open("CREDITCARDMSGSRSV1")
+		for _, account := range cardAccounts {
+			trnuid++
+			w.open("CCSTMTTRNRS")
+			w.leaf("TRNUID", fmt.Sprint(trnuid))
+			writeOFXStatus(w)
+			w.open("CCSTMTRS")
+			w.leaf("CURDEF", "USD")
+			w.open("CCACCTFROM")
+			w.leaf("ACCTID", ofxAccountName(account.Name, accountMapping))
+			w.close("CCACCTFROM")
+			writeOFXTransactions(w, account)
+			w.close("CCSTMTRS")
+			w.close("CCSTMTTRNRS")
+		}
+		w.close("CREDITCARDMSGSRSV1")
+	}
+	w.close("OFX")
+
+	err := os.WriteFile(outputFileName, []byte(w.b.String()), 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Println("OFX file written:", outputFileName)
+	return nil
+}
+
+func writeOFXStatus(w *ofxWriter) {
+	w.open("STATUS")
+	w.leaf("CODE", "0")
+	w.leaf("SEVERITY", "INFO")
+	w.close("STATUS")
+}
+
+func ofxAccountName(name string, accountMapping map[string]string) string {
+	if len(accountMapping[name]) > 0 {
+		return accountMapping[name]
+	}
+	return name
+}
+
+// writeOFXTransactions writes the BANKTRANLIST and the ledger balance. The
+// balance comes from the account header's $ and / lines when present and is
+// otherwise the sum of the transactions as of the last one.
+func writeOFXTransactions(w *ofxWriter, account *qifAccount) {
+	var start, end time.Time
+	var total money
+	for _, t := range account.Transactions {
+		total += t.Amount
+		if t.Date.IsZero() {
+			continue
+		}
+		if start.IsZero() || t.Date.Before(start) {
+			start = t.Date
+		}
+		if t.Date.After(end) {
+			end = t.Date
+		}
+	}
+
+	w.open("BANKTRANLIST")
+	w.leaf("DTSTART", start.Format("20060102"))
+	w.leaf("DTEND", end.Format("20060102"))
+	fitids := transactionFingerprints(account.Name, account.Transactions)
+	for i, t := range account.Transactions {
+		w.open("STMTTRN")
+		w.leaf("TRNTYPE", ofxTransactionType(t))
+		w.leaf("DTPOSTED", t.Date.Format("20060102"))
+		w.leaf("TRNAMT", t.Amount.String())
+		w.leaf("FITID", fitids[i])
+		if t.Number != "" {
+			w.leaf("CHECKNUM", t.Number)
+		}
+		if t.Payee != "" {
+			name := []rune(t.Payee)
+			// OFX limits NAME to 32 characters
+			if len(name) > 32 {
+				name = name[:32]
+			}
+			w.leaf("NAME", string(name))
+		}
+		if t.Memo != "" {
+			w.leaf("MEMO", t.Memo)
+		}
+		w.close("STMTTRN")
+	}
+	w.close("BANKTRANLIST")
+
+	balance := total
+	balanceDate := end
+	if account.Balance != "" {
+		if amount, err := parseMoney(account.Balance); err == nil {
+			balance = amount
+			if date, err := parseQIFDate(account.BalanceDate); err == nil {
+				balanceDate = date
+			}
+		}
+	}
+	w.open("LEDGERBAL")
+	w.leaf("BALAMT", balance.String())
+	w.leaf("DTASOF", balanceDate.Format("20060102"))
+	w.close("LEDGERBAL")
+}
+
+// ofxTransactionType picks TRNTYPE from the check number, transfer category
+// and sign of the amount.
+func ofxTransactionType(t *qifTransaction) string {
+	switch {
+	case t.Number != "" && strings.Trim(t.Number, "0123456789") == "":
+		return "CHECK"
+	case strings.HasPrefix(t.Category, "["):
+		return "XFER"
+	case t.Amount < 0:
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteOFXFileHeaderAndBankID(t *testing.T) {
+	qif := parseQIF("!Account\nNChecking\nTBank\n^\n!Type:Bank\nD3/ 5'24\nT-4.50\nPCafé Zürich\n^\n")
+	for _, sgml := range []bool{false, true} {
+		outputFileName := filepath.Join(t.TempDir(), "statement.ofx")
+		if err := writeOFXFile(qif, nil, "021000021", outputFileName, sgml); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(outputFileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := string(data)
+		header := `encoding="UTF-8"`
+		if sgml {
+			header = "ENCODING:UTF-8\nCHARSET:NONE\n"
+		}
+		for _, want := range []string{header, "<BANKID>021000021", "<ACCTTYPE>CHECKING", "<NAME>Café Zürich"} {
+			if !strings.Contains(content, want) {
+				t.Errorf("sgml=%v: output does not contain %q", sgml, want)
+			}
+		}
+	}
+}
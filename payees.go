@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// payeeProcessorPrefixes are card processor and bank prefixes placed in
+// front of the merchant name.
+var payeeProcessorPrefixes = []string{
+	"SQ *", "SQ*", "SQU*", "TST* ", "TST*", "PAYPAL *", "PAYPAL*", "PP*", "SP * ", "SP *", "SP+", "IC* ", "IC*",
+	"GOOGLE *", "CKE*", "DNH*", "POS ", "POS DEBIT ", "DEBIT CARD PURCHASE ", "CHECKCARD ", "PURCHASE ", "ACH ",
+}
+
+// payeeAliases expands abbreviations banks use for common merchants.
+var payeeAliases = map[string]string{
+	"AMZN": "AMAZON",
+	"WM":   "WALMART",
+	"WMT":  "WALMART",
+}
+
+// payeeNoiseWords are dropped from the cleaned name.
+var payeeNoiseWords = map[string]bool{
+	"THE": true, "INC": true, "LLC": true, "CO": true, "CORP": true, "LTD": true,
+	"MKTP": true, "MKTPLACE": true, "MARKETPLACE": true, "US": true, "USA": true,
+	"COM": true, "NET": true, "ORG": true, "WWW": true, "STORE": true, "WHSE": true,
+}
+
+// usStateCodes are the suffixes card statements add after the city.
+var usStateCodes = map[string]bool{
+	"AL": true, "AK": true, "AZ": true, "AR": true, "CA": true, "CO": true, "CT": true, "DE": true, "DC": true,
+	"FL": true, "GA": true, "HI": true, "ID": true, "IL": true, "IN": true, "IA": true, "KS": true, "KY": true,
+	"LA": true, "ME": true, "MD": true, "MA": true, "MI": true, "MN": true, "MS": true, "MO": true, "MT": true,
+	"NE": true, "NV": true, "NH": true, "NJ": true, "NM": true, "NY": true, "NC": true, "ND": true, "OH": true,
+	"OK": true, "OR": true, "PA": true, "RI": true, "SC": true, "SD": true, "TN": true, "TX": true, "UT": true,
+	"VT": true, "VA": true, "WA": true, "WV": true, "WI": true, "WY": true,
+}
+
+// runPayees is the payees subcommand. Its only action so far is suggest.
+func runPayees(args []string) {
+	if len(args) < 1 || args[0] != "suggest" {
+		fmt.Println("expected 'payees suggest'")
+		os.Exit(1)
+	}
+
+	suggestCmd := flag.NewFlagSet("payees suggest", flag.ExitOnError)
+	var inputFile inputFileList
+	suggestCmd.Var(&inputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
+	payeeList := suggestCmd.String("payeelist", "", "read payees from a list such as payeeList.txt instead of QIF files")
+	outputFile := suggestCmd.String("outputfile", "payeeMap.txt", "proposed payee mapping file for -payeemap")
+	threshold := suggestCmd.Float64("similarity", 0.5, "token similarity (0-1) needed to put two payees in one cluster")
+	suggestCmd.Parse(args[1:])
+	fmt.Println("subcommand 'payees suggest'")
+	fmt.Println("	inputfile:", inputFile.String())
+	fmt.Println("	payeelist:", *payeeList)
+	fmt.Println("	outputfile:", *outputFile)
+	fmt.Println("	similarity:", *threshold)
+
+	counts := make(map[string]int)
+	if *payeeList != "" {
+		file, err := os.Open(*payeeList)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			return
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if payee := strings.TrimSpace(scanner.Text()); payee != "" {
+				counts[payee]++
+			}
+		}
+		file.Close()
+		if err := scanner.Err(); err != nil {
+			fmt.Println("Error reading file:", err)
+			return
+		}
+	} else {
+		qif, err := loadQIFFiles(inputFile, nil)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			return
+		}
+		for _, account := range qif.Accounts {
+			for _, t := range account.Transactions {
+				if payee := strings.TrimSpace(t.Payee); payee != "" {
+					counts[payee]++
+				}
+			}
+		}
+	}
+
+	mapping, clusters := suggestPayeeMapping(counts, *threshold)
+	err := writePayeeSuggestions(*outputFile, mapping)
+	if err != nil {
+		fmt.Println("Error writing payee mapping:", err)
+		return
+	}
+	fmt.Printf("Payees: %d, clusters: %d, mappings written: %d\n", len(counts), clusters, len(mapping))
+}
+
+// cleanPayee strips card processor prefixes, "*" reference codes, store
+// numbers and what follows them, domain endings and a trailing "CITY ST"
+// from a payee and returns the remaining words in upper case.
+func cleanPayee(payee string) []string {
+	name := strings.ToUpper(strings.TrimSpace(payee))
+	for stripped := true; stripped; {
+		stripped = false
+		for _, prefix := range payeeProcessorPrefixes {
+			if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+				name = strings.TrimSpace(name[len(prefix):])
+				stripped = true
+			}
+		}
+	}
+	// Reference codes follow a "*", e.g. AMAZON.COM*2K4
+	if star := strings.Index(name, "*"); star > 0 {
+		name = name[:star]
+	}
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '&'
+	})
+	var cleaned []string
+	for _, word := range words {
+		word = strings.Trim(word, "'")
+		if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+			// A store number ends the name; the location follows it
+			if len(cleaned) > 0 {
+				break
+			}
+			continue
+		}
+		if word == "" || payeeNoiseWords[word] {
+			continue
+		}
+		if alias, ok := payeeAliases[word]; ok {
+			word = alias
+		}
+		cleaned = append(cleaned, word)
+	}
+	// Drop "CITY ST" when there is still a name in front of it
+	if n := len(cleaned); n >= 3 && usStateCodes[cleaned[n-1]] {
+		cleaned = cleaned[:n-2]
+	}
+	if len(cleaned) == 0 {
+		return strings.Fields(name)
+	}
+	return cleaned
+}
+
+// payeeSimilarity is the Jaccard similarity of two word lists.
+func payeeSimilarity(a []string, b []string) float64 {
+	set := make(map[string]bool)
+	for _, word := range a {
+		set[word] = true
+	}
+	shared := 0
+	union := len(set)
+	seen := make(map[string]bool)
+	for _, word := range b {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		if set[word] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// suggestPayeeMapping clusters payees whose cleaned names are at least
+// threshold similar and maps every payee to its cluster's name: the cleaned
+// name used by the most transactions, in title case. Payees already equal
+// to their cluster name are left out, and so are payees with a comma, which
+// the mapping file cannot hold.
+func suggestPayeeMapping(counts map[string]int, threshold float64) (map[string]string, int) {
+	var payees []string
+	for payee := range counts {
+		payees = append(payees, payee)
+	}
+	sort.Strings(payees)
+
+	cleaned := make([][]string, len(payees))
+	for i, payee := range payees {
+		cleaned[i] = cleanPayee(payee)
+	}
+
+	parent := make([]int, len(payees))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range payees {
+		for j := i + 1; j < len(payees); j++ {
+			if payeeSimilarity(cleaned[i], cleaned[j]) >= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	clusters := make(map[int][]int)
+	for i := range payees {
+		root := find(i)
+		clusters[root] = append(clusters[root], i)
+	}
+
+	mapping := make(map[string]string)
+	for _, members := range clusters {
+		names := make(map[string]int)
+		for _, i := range members {
+			names[strings.Join(cleaned[i], " ")] += counts[payees[i]]
+		}
+		best := ""
+		for name, count := range names {
+			if best == "" || count > names[best] || (count == names[best] && (len(name) < len(best) || (len(name) == len(best) && name < best))) {
+				best = name
+			}
+		}
+		canonical := titleCasePayee(best)
+		for _, i := range members {
+			if payees[i] != canonical && !strings.Contains(payees[i], ",") {
+				mapping[payees[i]] = canonical
+			}
+		}
+	}
+	return mapping, len(clusters)
+}
+
+// titleCasePayee turns "HOME DEPOT" into "Home Depot".
+func titleCasePayee(name string) string {
+	words := strings.Fields(strings.ToLower(name))
+	for i, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
+
+// writePayeeSuggestions writes the mapping sorted by target name. -payeemap
+// replaces keys anywhere in a payee, so keys that contain another key are
+// reported: depending on the order they are applied, the longer one may not
+// match.
+func writePayeeSuggestions(outputFileName string, mapping map[string]string) error {
+	var keys []string
+	for key := range mapping {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if mapping[keys[i]] != mapping[keys[j]] {
+			return mapping[keys[i]] < mapping[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		for _, other := range keys {
+			if other != key && strings.Contains(key, other) {
+				fmt.Printf("Warning: payee %q contains %q, review the mapping for both\n", key, other)
+			}
+		}
+	}
+
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+	for _, key := range keys {
+		fmt.Fprintf(writer, "%s,%s\n", key, mapping[key])
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,685 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// qifAccount holds the fields of an !Account record along with the register
+// transactions that follow it in the file.
+type qifAccount struct {
+	Name         string
+	Type         string
+	Description  string
+	CreditLimit  string
+	Balance      string
+	BalanceDate  string
+	Transactions []*qifTransaction
+	Investments  []*qifInvestment
+}
+
+// qifTransaction is a single register entry from a !Type:Bank, CCard, Cash,
+// Oth A or Oth L block.
+type qifTransaction struct {
+	Date     time.Time
+	RawDate  string
+	Amount   money
+	Cleared  string
+	Number   string
+	Payee    string
+	Memo     string
+	Category string
+	Address  []string
+	Splits   []*qifSplit
+	Source   string
+
+	// Payee and category as read, before any mapping or suggestion changed them
+	OriginalPayee    string
+	OriginalCategory string
+}
+
+// qifSplit is one S/E/$ group of a split transaction.
+type qifSplit struct {
+	Category string
+	Memo     string
+	Amount   money
+	Percent  string
+}
+
+// qifMemorized is a !Type:Memorized record. Kind is the K line: C check,
+// D deposit, P payment, I investment or E electronic payee.
+type qifMemorized struct {
+	*qifTransaction
+	Kind string
+}
+
+// qifCategory is a !Type:Cat record. TaxSchedule is the R line.
+type qifCategory struct {
+	Name        string
+	Description string
+	Income      bool
+	Expense     bool
+	TaxRelated  bool
+	TaxSchedule string
+	// Budget holds the B lines in order: one per month from January, or a
+	// single amount used for every month
+	Budget []money
+}
+
+// monthlyBudget spreads the B lines over the twelve months.
+func (c *qifCategory) monthlyBudget() [12]money {
+	var months [12]money
+	if len(c.Budget) == 1 {
+		for i := range months {
+			months[i] = c.Budget[0]
+		}
+		return months
+	}
+	copy(months[:], c.Budget)
+	return months
+}
+
+// qifTag is a !Type:Tag record; qifClass is the matching !Type:Class record
+// from older Quicken versions.
+type qifTag struct {
+	Name        string
+	Description string
+}
+
+type qifClass struct {
+	Name        string
+	Description string
+}
+
+// qifInvestment is a !Type:Invst record. Action is the N line (Buy, Sell,
+// ReinvDiv, StkSplit, ...), Security the Y line, Price the I line and
+// Quantity the Q line, which for StkSplit holds new shares per 10 old ones.
+type qifInvestment struct {
+	Date           time.Time
+	RawDate        string
+	Action         string
+	Security       string
+	Price          float64
+	Quantity       float64
+	Amount         money
+	Commission     money
+	Cleared        string
+	Payee          string
+	Memo           string
+	Category       string
+	TransferAmount money
+	Source         string
+}
+
+// qifSecurity is a !Type:Security record linking a security name to the
+// symbol used in the price list.
+type qifSecurity struct {
+	Name   string
+	Symbol string
+	Type   string
+	Goal   string
+}
+
+// qifPrice is one line of a !Type:Prices record.
+type qifPrice struct {
+	Security string
+	Price    float64
+	Date     time.Time
+}
+
+// qifFile is the parsed content of a QIF export.
+type qifFile struct {
+	Accounts   []*qifAccount
+	Categories []*qifCategory
+	Tags       []*qifTag
+	Classes    []*qifClass
+	Memorized  []*qifMemorized
+	Securities []*qifSecurity
+	Prices     []*qifPrice
+	Warnings   []string
+}
+
+// registerTypes are the !Type headers whose records are register transactions.
+var registerTypes = map[string]bool{
+	"Bank":  true,
+	"Cash":  true,
+	"CCard": true,
+	"Oth A": true,
+	"Oth L": true,
+}
+
+// loadQIFFile reads and parses a QIF file from disk.
+func loadQIFFile(inputFileName string) (*qifFile, error) {
+	inputBytes, err := os.ReadFile(inputFileName)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
+	qif := parseQIF(string(inputBytes))
+	for _, warning := range qif.Warnings {
+		fmt.Println("Warning:", warning)
+	}
+	return qif, nil
+}
+
+// loadQIFFiles parses several QIF files into one. Each transaction records
+// the file it came from, and accounts whose names map to the same target in
+// accountMapping are merged under the first account seen.
+func loadQIFFiles(inputFileNames []string, accountMapping map[string]string) (*qifFile, error) {
+	merged := &qifFile{}
+	accountsByName := make(map[string]*qifAccount)
+	for _, inputFileName := range inputFileNames {
+		qif, err := loadQIFFile(inputFileName)
+		if err != nil {
+			return nil, err
+		}
+		source := filepath.Base(inputFileName)
+		for _, account := range qif.Accounts {
+			// A register without an account header is named after its
+			// file, so it can be mapped and is not merged with another
+			// file's unnamed register
+			if account.Name == "" {
+				account.Name = strings.TrimSuffix(source, filepath.Ext(source))
+			}
+			for _, t := range account.Transactions {
+				t.Source = source
+			}
+			for _, investment := range account.Investments {
+				investment.Source = source
+			}
+			key := account.Name
+			if len(accountMapping[key]) > 0 {
+				key = accountMapping[key]
+			}
+			if existing, ok := accountsByName[key]; ok {
+				mergeAccount(existing, account)
+				existing.Transactions = append(existing.Transactions, account.Transactions...)
+				existing.Investments = append(existing.Investments, account.Investments...)
+				continue
+			}
+			accountsByName[key] = account
+			merged.Accounts = append(merged.Accounts, account)
+		}
+		merged.Categories = append(merged.Categories, qif.Categories...)
+		merged.Tags = append(merged.Tags, qif.Tags...)
+		merged.Classes = append(merged.Classes, qif.Classes...)
+		merged.Memorized = append(merged.Memorized, qif.Memorized...)
+		merged.Securities = append(merged.Securities, qif.Securities...)
+		merged.Prices = append(merged.Prices, qif.Prices...)
+		merged.Warnings = append(merged.Warnings, qif.Warnings...)
+	}
+	return merged, nil
+}
+
+// readInputFiles returns the text of the input files joined together, for the
+// extractions that scan the raw file content.
+func readInputFiles(inputFileNames []string) string {
+	var contents []string
+	for _, inputFileName := range inputFileNames {
+		inputBytes, err := os.ReadFile(inputFileName)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			continue
+		}
+		fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
+		contents = append(contents, string(inputBytes))
+	}
+	return strings.Join(contents, "\n")
+}
+
+// parseQIF walks the file line by line. Records are terminated by "^" and the
+// meaning of a record depends on the last "!" header seen.
+func parseQIF(inputContent string) *qifFile {
+	qif := &qifFile{}
+	accountsByName := make(map[string]*qifAccount)
+	var currentAccount *qifAccount
+	var record []string
+	section := ""
+	autoSwitch := false
+
+	// Standardize Line Endings
+	inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
+
+	for _, line := range strings.Split(inputContent, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "!") {
+			header := strings.TrimSpace(line[1:])
+			switch {
+			case strings.EqualFold(header, "Option:AutoSwitch"):
+				autoSwitch = true
+			case strings.EqualFold(header, "Clear:AutoSwitch"):
+				autoSwitch = false
+			case strings.EqualFold(header, "Account"):
+				section = "Account"
+			case strings.HasPrefix(header, "Type:"):
+				section = strings.TrimSpace(header[len("Type:"):])
+			default:
+				section = header
+			}
+			record = nil
+			continue
+		}
+
+		if line != "^" {
+			record = append(record, line)
+			continue
+		}
+
+		switch {
+		case section == "Account":
+			account := parseAccountRecord(record)
+			if existing, ok := accountsByName[account.Name]; ok {
+				mergeAccount(existing, account)
+				account = existing
+			} else {
+				accountsByName[account.Name] = account
+				qif.Accounts = append(qif.Accounts, account)
+			}
+			// Outside of an AutoSwitch list an !Account record introduces the
+			// transactions that follow it.
+			if !autoSwitch {
+				currentAccount = account
+			}
+		case registerTypes[section]:
+			if currentAccount == nil {
+				// Transactions without an account header belong to an
+				// unnamed account of the block's type.
+				currentAccount = &qifAccount{Type: section}
+				qif.Accounts = append(qif.Accounts, currentAccount)
+			}
+			transaction, errs := parseTransactionRecord(record)
+			for _, err := range errs {
+				qif.Warnings = append(qif.Warnings, fmt.Sprintf("account %s, %s: %s", currentAccount.Name, transaction.RawDate, err))
+			}
+			currentAccount.Transactions = append(currentAccount.Transactions, transaction)
+		case section == "Cat":
+			category, errs := parseCategoryRecord(record)
+			for _, err := range errs {
+				qif.Warnings = append(qif.Warnings, fmt.Sprintf("category %s: %s", category.Name, err))
+			}
+			qif.Categories = append(qif.Categories, category)
+		case section == "Tag":
+			name, description := parseNameRecord(record)
+			qif.Tags = append(qif.Tags, &qifTag{Name: name, Description: description})
+		case section == "Class":
+			name, description := parseNameRecord(record)
+			qif.Classes = append(qif.Classes, &qifClass{Name: name, Description: description})
+		case section == "Memorized":
+			memorized, errs := parseMemorizedRecord(record)
+			for _, err := range errs {
+				qif.Warnings = append(qif.Warnings, fmt.Sprintf("memorized %s: %s", memorized.Payee, err))
+			}
+			qif.Memorized = append(qif.Memorized, memorized)
+		case section == "Invst":
+			if currentAccount == nil {
+				currentAccount = &qifAccount{Type: "Invst"}
+				qif.Accounts = append(qif.Accounts, currentAccount)
+			}
+			investment, errs := parseInvestmentRecord(record)
+			for _, err := range errs {
+				qif.Warnings = append(qif.Warnings, fmt.Sprintf("account %s, %s: %s", currentAccount.Name, investment.RawDate, err))
+			}
+			currentAccount.Investments = append(currentAccount.Investments, investment)
+		case section == "Security":
+			qif.Securities = append(qif.Securities, parseSecurityRecord(record))
+		case section == "Prices":
+			for _, line := range record {
+				price, err := parsePriceLine(line)
+				if err != nil {
+					qif.Warnings = append(qif.Warnings, fmt.Sprintf("price %s: %s", line, err))
+					continue
+				}
+				qif.Prices = append(qif.Prices, price)
+			}
+		}
+		record = nil
+	}
+
+	return qif
+}
+
+func parseCategoryRecord(record []string) (*qifCategory, []error) {
+	category := &qifCategory{}
+	var errs []error
+	for _, line := range record {
+		value := strings.TrimSpace(line[1:])
+		switch line[0] {
+		case 'N':
+			category.Name = value
+		case 'D':
+			category.Description = value
+		case 'I':
+			category.Income = true
+		case 'E':
+			category.Expense = true
+		case 'T':
+			category.TaxRelated = true
+		case 'R':
+			category.TaxSchedule = value
+		case 'B':
+			// An unreadable amount still takes its month, as zero
+			amount, err := parseMoney(value)
+			if err != nil {
+				errs = append(errs, err)
+				amount = 0
+			}
+			category.Budget = append(category.Budget, amount)
+		}
+	}
+	return category, errs
+}
+
+// parseNameRecord reads the N and D lines shared by tag and class records.
+func parseNameRecord(record []string) (name string, description string) {
+	for _, line := range record {
+		value := strings.TrimSpace(line[1:])
+		switch line[0] {
+		case 'N':
+			name = value
+		case 'D':
+			description = value
+		}
+	}
+	return name, description
+}
+
+func parseAccountRecord(record []string) *qifAccount {
+	account := &qifAccount{}
+	for _, line := range record {
+		value := strings.TrimSpace(line[1:])
+		switch line[0] {
+		case 'N':
+			account.Name = value
+		case 'T':
+			account.Type = value
+		case 'D':
+			account.Description = value
+		case 'L':
+			account.CreditLimit = value
+		case '$':
+			account.Balance = value
+		case '/':
+			account.BalanceDate = value
+		}
+	}
+	return account
+}
+
+// mergeAccount copies any metadata present on a repeated account header onto
+// the account first seen with that name.
+func mergeAccount(existing *qifAccount, account *qifAccount) {
+	if account.Type != "" {
+		existing.Type = account.Type
+	}
+	if account.Description != "" {
+		existing.Description = account.Description
+	}
+	if account.CreditLimit != "" {
+		existing.CreditLimit = account.CreditLimit
+	}
+	if account.Balance != "" {
+		existing.Balance = account.Balance
+	}
+	if account.BalanceDate != "" {
+		existing.BalanceDate = account.BalanceDate
+	}
+}
+
+// parseTransactionRecord builds a transaction from its lines. Amount problems
+// are returned alongside the transaction so the caller can report them; the
+// U and T amounts must agree and splits must add up to the total.
+func parseTransactionRecord(record []string) (*qifTransaction, []error) {
+	transaction := &qifTransaction{}
+	var errs []error
+	var split *qifSplit
+	var amountU, amountT string
+	percentSplits := false
+	for _, line := range record {
+		value := strings.TrimSpace(line[1:])
+		switch line[0] {
+		case 'D':
+			transaction.RawDate = value
+			transaction.Date, _ = parseQIFDate(value)
+		case 'U':
+			amountU = value
+		case 'T':
+			amountT = value
+		case 'C':
+			transaction.Cleared = value
+		case 'N':
+			transaction.Number = value
+		case 'P':
+			transaction.Payee = value
+		case 'M':
+			transaction.Memo = value
+		case 'L':
+			transaction.Category = value
+		case 'A':
+			transaction.Address = append(transaction.Address, value)
+		case 'S':
+			split = &qifSplit{Category: value}
+			transaction.Splits = append(transaction.Splits, split)
+		case 'E':
+			if split != nil {
+				split.Memo = value
+			}
+		case '$':
+			if split != nil {
+				amount, err := parseMoney(value)
+				if err != nil {
+					errs = append(errs, err)
+				}
+				split.Amount = amount
+			}
+		case '%':
+			if split != nil {
+				split.Percent = value
+				percentSplits = true
+			}
+		}
+	}
+
+	u, err := parseMoney(amountU)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	t, err := parseMoney(amountT)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	// Older exports only carry the T amount
+	transaction.Amount = u
+	if amountU == "" {
+		transaction.Amount = t
+	} else if amountT != "" && u != t {
+		errs = append(errs, fmt.Errorf("U amount %s does not match T amount %s", u, t))
+	}
+
+	if len(transaction.Splits) > 0 && !percentSplits {
+		var splitTotal money
+		for _, split := range transaction.Splits {
+			splitTotal += split.Amount
+		}
+		if splitTotal != transaction.Amount {
+			errs = append(errs, fmt.Errorf("splits total %s does not match amount %s", splitTotal, transaction.Amount))
+		}
+	}
+	transaction.OriginalPayee = transaction.Payee
+	transaction.OriginalCategory = transaction.Category
+	return transaction, errs
+}
+
+func parseMemorizedRecord(record []string) (*qifMemorized, []error) {
+	transaction, errs := parseTransactionRecord(record)
+	memorized := &qifMemorized{qifTransaction: transaction}
+	for _, line := range record {
+		if line[0] == 'K' {
+			memorized.Kind = strings.TrimSpace(line[1:])
+		}
+	}
+	return memorized, errs
+}
+
+// parseInvestmentRecord builds an investment transaction from its lines.
+func parseInvestmentRecord(record []string) (*qifInvestment, []error) {
+	investment := &qifInvestment{}
+	var errs []error
+	var amountU, amountT string
+	for _, line := range record {
+		value := strings.TrimSpace(line[1:])
+		var err error
+		switch line[0] {
+		case 'D':
+			investment.RawDate = value
+			investment.Date, _ = parseQIFDate(value)
+		case 'N':
+			investment.Action = value
+		case 'Y':
+			investment.Security = value
+		case 'I':
+			investment.Price, err = parseQuantity(value)
+		case 'Q':
+			investment.Quantity, err = parseQuantity(value)
+		case 'U':
+			amountU = value
+		case 'T':
+			amountT = value
+		case 'O':
+			investment.Commission, err = parseMoney(value)
+		case 'C':
+			investment.Cleared = value
+		case 'P':
+			investment.Payee = value
+		case 'M':
+			investment.Memo = value
+		case 'L':
+			investment.Category = value
+		case '$':
+			investment.TransferAmount, err = parseMoney(value)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	amount := amountT
+	if amount == "" {
+		amount = amountU
+	}
+	var err error
+	investment.Amount, err = parseMoney(amount)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return investment, errs
+}
+
+// parseQuantity reads share counts and prices, which can have more decimals
+// than money amounts and, in older files, fractions such as "141 1/4".
+func parseQuantity(value string) (float64, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", "")
+	if value == "" {
+		return 0, nil
+	}
+	whole, fraction, found := strings.Cut(value, " ")
+	if !found && strings.Contains(value, "/") {
+		whole, fraction = "0", value
+	}
+	quantity, err := strconv.ParseFloat(whole, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity: %s", value)
+	}
+	if fraction != "" {
+		numerator, denominator, ok := strings.Cut(strings.TrimSpace(fraction), "/")
+		n, err1 := strconv.ParseFloat(numerator, 64)
+		d, err2 := strconv.ParseFloat(denominator, 64)
+		if !ok || err1 != nil || err2 != nil || d == 0 {
+			return 0, fmt.Errorf("invalid quantity: %s", value)
+		}
+		if quantity < 0 || strings.HasPrefix(whole, "-") {
+			quantity -= n / d
+		} else {
+			quantity += n / d
+		}
+	}
+	return quantity, nil
+}
+
+func parseSecurityRecord(record []string) *qifSecurity {
+	security := &qifSecurity{}
+	for _, line := range record {
+		value := strings.TrimSpace(line[1:])
+		switch line[0] {
+		case 'N':
+			security.Name = value
+		case 'S':
+			security.Symbol = value
+		case 'T':
+			security.Type = value
+		case 'G':
+			security.Goal = value
+		}
+	}
+	return security
+}
+
+// parsePriceLine reads a price list line: "SYMBOL",price,"date".
+func parsePriceLine(line string) (*qifPrice, error) {
+	parts := strings.Split(line, ",")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("expected symbol, price and date")
+	}
+	unquote := func(value string) string {
+		return strings.Trim(strings.TrimSpace(value), "\"")
+	}
+	price, err := parseQuantity(unquote(parts[1]))
+	if err != nil {
+		return nil, err
+	}
+	date, err := parseQIFDate(unquote(strings.Join(parts[2:], ",")))
+	if err != nil {
+		return nil, err
+	}
+	return &qifPrice{Security: unquote(parts[0]), Price: price, Date: date}, nil
+}
+
+// parseQIFDate understands the M/D'YY, M/D/YY and M/D/YYYY forms Quicken
+// writes. An apostrophe before a two digit year means 20xx.
+func parseQIFDate(value string) (time.Time, error) {
+	value = strings.ReplaceAll(value, " ", "")
+	century := 1900
+	if strings.Contains(value, "'") {
+		century = 2000
+		value = strings.Replace(value, "'", "/", 1)
+	}
+	value = strings.ReplaceAll(value, "-", "/")
+
+	parts := strings.Split(value, "/")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid date: %s", value)
+	}
+	month, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid month in date: %s", value)
+	}
+	day, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid day in date: %s", value)
+	}
+	year, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid year in date: %s", value)
+	}
+	if len(parts[2]) <= 2 {
+		year += century
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTransactionRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		record string
+		amount money
+		errors []string
+	}{
+		{"U and T agree", "D1/ 5'24\nU-1,234.56\nT-1,234.56\nPLandlord", -123456, nil},
+		{"T only", "D1/ 5'24\nT45.20\nPAcme", 4520, nil},
+		{"U only", "D1/ 5'24\nU(12.00)\nPAcme", -1200, nil},
+		{"U and T differ", "D1/ 5'24\nU-10.00\nT-12.00\nPAcme", -1000, []string{"U amount -10.00 does not match T amount -12.00"}},
+		{"bad amount", "D1/ 5'24\nT12.345\nPAcme", 0, []string{"too many decimal places"}},
+		{"splits add up", "D1/ 5'24\nT-150.00\nSGroceries\n$-120.00\nSHousehold\n$-30.00", -15000, nil},
+		{"splits do not add up", "D1/ 5'24\nT-150.00\nSGroceries\n$-120.00\nSHousehold\n$-20.00", -15000, []string{"splits total -140.00 does not match amount -150.00"}},
+		{"percent splits are not totalled", "D1/ 5'24\nT-150.00\nSGroceries\n%80%\n$-100.00", -15000, nil},
+		{"bad split amount", "D1/ 5'24\nT-10.00\nSGroceries\n$ten", -1000, []string{"invalid amount", "splits total 0.00 does not match"}},
+	}
+	for _, test := range tests {
+		transaction, errs := parseTransactionRecord(strings.Split(test.record, "\n"))
+		if transaction.Amount != test.amount {
+			t.Errorf("%s: amount %s, want %s", test.name, transaction.Amount, test.amount)
+		}
+		if len(errs) != len(test.errors) {
+			t.Errorf("%s: got errors %v, want %d", test.name, errs, len(test.errors))
+			continue
+		}
+		for i, want := range test.errors {
+			if !strings.Contains(errs[i].Error(), want) {
+				t.Errorf("%s: error %d is %q, want it to contain %q", test.name, i, errs[i], want)
+			}
+		}
+	}
+}
+
+func TestParseTransactionRecordFields(t *testing.T) {
+	record := "D1/ 5'24\nT-45.00\nCX\nN1001\nPCity Power\nMJanuary\nA1 Main St\nASpringfield\nLUtilities:Electric/Home"
+	transaction, errs := parseTransactionRecord(strings.Split(record, "\n"))
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got := transaction.Date.Format("2006-01-02"); got != "2024-01-05" {
+		t.Errorf("date %s, want 2024-01-05", got)
+	}
+	if transaction.RawDate != "1/ 5'24" || transaction.Cleared != "X" || transaction.Number != "1001" ||
+		transaction.Payee != "City Power" || transaction.Memo != "January" || transaction.Category != "Utilities:Electric/Home" {
+		t.Errorf("unexpected fields: %+v", *transaction)
+	}
+	if strings.Join(transaction.Address, "|") != "1 Main St|Springfield" {
+		t.Errorf("address %q", transaction.Address)
+	}
+	if transaction.OriginalPayee != transaction.Payee || transaction.OriginalCategory != transaction.Category {
+		t.Errorf("original payee and category not kept: %q, %q", transaction.OriginalPayee, transaction.OriginalCategory)
+	}
+}
+
+func TestParseQIFRegisterTypes(t *testing.T) {
+	qif := parseQIF(`!Account
+NWallet
+TCash
+^
+!Type:Cash
+D1/ 5'24
+T-5.00
+PCoffee
+^
+!Account
+NHouse
+TOth A
+^
+!Type:Oth A
+D1/ 6'24
+T1,000.00
+PRevaluation
+^
+!Account
+NMortgage
+TOth L
+^
+!Type:Oth L
+D1/ 7'24
+T-1,500.00
+PPayment
+^
+`)
+	if len(qif.Warnings) > 0 {
+		t.Fatalf("unexpected warnings: %v", qif.Warnings)
+	}
+	want := []struct {
+		name   string
+		kind   string
+		amount money
+	}{
+		{"Wallet", "Cash", -500},
+		{"House", "Oth A", 100000},
+		{"Mortgage", "Oth L", -150000},
+	}
+	if len(qif.Accounts) != len(want) {
+		t.Fatalf("got %d accounts, want %d", len(qif.Accounts), len(want))
+	}
+	for i, w := range want {
+		account := qif.Accounts[i]
+		if account.Name != w.name || account.Type != w.kind {
+			t.Errorf("account %d is %s (%s), want %s (%s)", i, account.Name, account.Type, w.name, w.kind)
+		}
+		if len(account.Transactions) != 1 || account.Transactions[0].Amount != w.amount {
+			t.Errorf("account %s: unexpected transactions %v", w.name, account.Transactions)
+		}
+	}
+}
+
+func TestParseQIFWarnings(t *testing.T) {
+	qif := parseQIF("!Account\nNChecking\nTBank\n^\n!Type:Bank\nD1/ 5'24\nU-10.00\nT-12.00\nPAcme\n^\n")
+	if len(qif.Warnings) != 1 || !strings.HasPrefix(qif.Warnings[0], "account Checking, 1/ 5'24: U amount") {
+		t.Errorf("unexpected warnings: %v", qif.Warnings)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeQIFFile writes the parsed file back out as QIF and checks that the
+// result reads back to the same content.
+func writeQIFFile(qif *qifFile, accountMapping map[string]string, outputFileName string) error {
+	content := formatQIF(qif, accountMapping)
+	err := os.WriteFile(outputFileName, []byte(content), 0644)
+	if err != nil {
+		return err
+	}
+
+	// Parsing the output must give back as many records as were read, and
+	// writing it again must give the same text, otherwise something was lost
+	// on the way through.
+	written := parseQIF(content)
+	if got, want := qifRecordCounts(written), qifRecordCounts(qif); got != want {
+		fmt.Printf("Warning: QIF output holds %s, expected %s: %s\n", got, want, outputFileName)
+	}
+	if formatQIF(written, nil) != content {
+		fmt.Println("Warning: QIF output does not read back identically:", outputFileName)
+	}
+
+	fmt.Println("QIF file written:", outputFileName)
+	return nil
+}
+
+// qifRecordCounts summarizes how many records of each kind a file holds.
+func qifRecordCounts(qif *qifFile) string {
+	transactions, investments := 0, 0
+	for _, account := range qif.Accounts {
+		transactions += len(account.Transactions)
+		investments += len(account.Investments)
+	}
+	return fmt.Sprintf("%d transactions, %d investment transactions, %d memorized, %d securities and %d prices",
+		transactions, investments, len(qif.Memorized), len(qif.Securities), len(qif.Prices))
+}
+
+// formatQIF renders the tag, category and class lists, the account list, the
+// securities, the memorized transactions, each account's register and then
+// the price list. Account names are renamed through accountMapping,
+// including in transfer categories.
+func formatQIF(qif *qifFile, accountMapping map[string]string) string {
+	var b strings.Builder
+	accountName := func(name string) string {
+		if len(accountMapping[name]) > 0 {
+			return accountMapping[name]
+		}
+		return name
+	}
+	category := func(value string) string {
+		// Transfers name the other account in brackets
+		if strings.HasPrefix(value, "[") {
+			if end := strings.Index(value, "]"); end > 0 {
+				return "[" + accountName(value[1:end]) + "]" + value[end+1:]
+			}
+		}
+		return value
+	}
+
+	if len(qif.Tags) > 0 {
+		b.WriteString("!Type:Tag\n")
+		for _, tag := range qif.Tags {
+			writeQIFLine(&b, 'N', tag.Name)
+			writeQIFLine(&b, 'D', tag.Description)
+			b.WriteString("^\n")
+		}
+	}
+
+	if len(qif.Categories) > 0 {
+		b.WriteString("!Type:Cat\n")
+		for _, c := range qif.Categories {
+			writeQIFLine(&b, 'N', c.Name)
+			writeQIFLine(&b, 'D', c.Description)
+			if c.TaxRelated {
+				b.WriteString("T\n")
+			}
+			if c.Income {
+				b.WriteString("I\n")
+			}
+			if c.Expense {
+				b.WriteString("E\n")
+			}
+			writeQIFLine(&b, 'R', c.TaxSchedule)
+			for _, amount := range c.Budget {
+				writeQIFLine(&b, 'B', amount.String())
+			}
+			b.WriteString("^\n")
+		}
+	}
+
+	if len(qif.Classes) > 0 {
+		b.WriteString("!Type:Class\n")
+		for _, class := range qif.Classes {
+			writeQIFLine(&b, 'N', class.Name)
+			writeQIFLine(&b, 'D', class.Description)
+			b.WriteString("^\n")
+		}
+	}
+
+	if len(qif.Accounts) > 0 {
+		b.WriteString("!Option:AutoSwitch\n!Account\n")
+		for _, account := range qif.Accounts {
+			writeQIFAccount(&b, account, accountName(account.Name))
+		}
+		b.WriteString("!Clear:AutoSwitch\n")
+	}
+
+	// Quicken writes a header before each security
+	for _, security := range qif.Securities {
+		b.WriteString("!Type:Security\n")
+		writeQIFLine(&b, 'N', security.Name)
+		writeQIFLine(&b, 'S', security.Symbol)
+		writeQIFLine(&b, 'T', security.Type)
+		writeQIFLine(&b, 'G', security.Goal)
+		b.WriteString("^\n")
+	}
+
+	if len(qif.Memorized) > 0 {
+		b.WriteString("!Type:Memorized\n")
+		for _, m := range qif.Memorized {
+			writeQIFLine(&b, 'K', m.Kind)
+			writeQIFTransaction(&b, m.qifTransaction, category)
+		}
+	}
+
+	for _, account := range qif.Accounts {
+		if len(account.Transactions) == 0 && len(account.Investments) == 0 {
+			continue
+		}
+		b.WriteString("!Account\n")
+		writeQIFLine(&b, 'N', accountName(account.Name))
+		writeQIFLine(&b, 'T', qifAccountType(account))
+		b.WriteString("^\n")
+		if len(account.Transactions) > 0 {
+			b.WriteString("!Type:" + qifAccountType(account) + "\n")
+			for _, t := range account.Transactions {
+				writeQIFTransaction(&b, t, category)
+			}
+		}
+		if len(account.Investments) > 0 {
+			b.WriteString("!Type:Invst\n")
+			for _, investment := range account.Investments {
+				writeQIFInvestment(&b, investment, category)
+			}
+		}
+	}
+
+	// Quicken writes each price as a record of its own
+	for _, price := range qif.Prices {
+		b.WriteString("!Type:Prices\n")
+		fmt.Fprintf(&b, "\"%s\",%s,\"%s\"\n", price.Security, formatQIFQuantity(price.Price), formatQIFDate(price.Date))
+		b.WriteString("^\n")
+	}
+
+	return b.String()
+}
+
+func writeQIFAccount(b *strings.Builder, account *qifAccount, name string) {
+	writeQIFLine(b, 'N', name)
+	writeQIFLine(b, 'T', qifAccountType(account))
+	writeQIFLine(b, 'D', account.Description)
+	writeQIFLine(b, 'L', account.CreditLimit)
+	writeQIFLine(b, '/', account.BalanceDate)
+	writeQIFLine(b, '$', account.Balance)
+	b.WriteString("^\n")
+}
+
+// qifAccountType is the account's type, or for an account header that had
+// no T line Invst or Bank depending on its register, so the register is read
+// back as transactions.
+func qifAccountType(account *qifAccount) string {
+	if account.Type == "" {
+		if len(account.Investments) > 0 && len(account.Transactions) == 0 {
+			return "Invst"
+		}
+		return "Bank"
+	}
+	return account.Type
+}
+
+func writeQIFTransaction(b *strings.Builder, t *qifTransaction, category func(string) string) {
+	if !t.Date.IsZero() {
+		writeQIFLine(b, 'D', formatQIFDate(t.Date))
+	}
+	writeQIFLine(b, 'U', t.Amount.String())
+	writeQIFLine(b, 'T', t.Amount.String())
+	writeQIFLine(b, 'C', t.Cleared)
+	writeQIFLine(b, 'N', t.Number)
+	writeQIFLine(b, 'P', t.Payee)
+	writeQIFLine(b, 'M', t.Memo)
+	for _, line := range t.Address {
+		writeQIFLine(b, 'A', line)
+	}
+	writeQIFLine(b, 'L', category(t.Category))
+	for _, split := range t.Splits {
+		b.WriteString("S" + category(split.Category) + "\n")
+		writeQIFLine(b, 'E', split.Memo)
+		writeQIFLine(b, '%', split.Percent)
+		b.WriteString("$" + split.Amount.String() + "\n")
+	}
+	b.WriteString("^\n")
+}
+
+func writeQIFInvestment(b *strings.Builder, investment *qifInvestment, category func(string) string) {
+	if !investment.Date.IsZero() {
+		writeQIFLine(b, 'D', formatQIFDate(investment.Date))
+	}
+	writeQIFLine(b, 'N', investment.Action)
+	writeQIFLine(b, 'Y', investment.Security)
+	writeQIFLine(b, 'I', formatQIFQuantity(investment.Price))
+	writeQIFLine(b, 'Q', formatQIFQuantity(investment.Quantity))
+	if investment.Amount != 0 {
+		writeQIFLine(b, 'U', investment.Amount.String())
+		writeQIFLine(b, 'T', investment.Amount.String())
+	}
+	writeQIFLine(b, 'C', investment.Cleared)
+	writeQIFLine(b, 'P', investment.Payee)
+	writeQIFLine(b, 'M', investment.Memo)
+	if investment.Commission != 0 {
+		writeQIFLine(b, 'O', investment.Commission.String())
+	}
+	writeQIFLine(b, 'L', category(investment.Category))
+	if investment.TransferAmount != 0 {
+		writeQIFLine(b, '$', investment.TransferAmount.String())
+	}
+	b.WriteString("^\n")
+}
+
+// formatQIFQuantity writes a share count or price with the decimals it needs,
+// leaving out zero.
+func formatQIFQuantity(quantity float64) string {
+	if quantity == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(quantity, 'f', -1, 64)
+}
+
+// writeQIFLine writes a field line, leaving out empty values.
+func writeQIFLine(b *strings.Builder, code byte, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteByte(code)
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// formatQIFDate writes dates the way Quicken does: M/D'YY from 2000 on and
+// M/D/YY before.
+func formatQIFDate(date time.Time) string {
+	if date.Year() >= 2000 && date.Year() < 2100 {
+		return fmt.Sprintf("%d/%2d'%02d", int(date.Month()), date.Day(), date.Year()%100)
+	}
+	if date.Year() >= 1900 && date.Year() < 2000 {
+		return fmt.Sprintf("%d/%2d/%02d", int(date.Month()), date.Day(), date.Year()%100)
+	}
+	return fmt.Sprintf("%d/%d/%04d", int(date.Month()), date.Day(), date.Year())
+}
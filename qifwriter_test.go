@@ -0,0 +1,324 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const roundTripFixture = `!Type:Tag
+NVacation
+DTrips away
+^
+NWork
+^
+!Type:Cat
+NGroceries
+DFood at home
+E
+B400.00
+^
+NSalary
+I
+T
+RW-2 Salary
+B5,000.00
+B5,000.00
+B5,200.00
+^
+NMedical:Dental
+E
+T
+RSchedule A:Medicine
+^
+!Type:Class
+NBusiness
+DSide business
+^
+!Option:AutoSwitch
+!Account
+NChecking
+TBank
+DPrimary checking
+/12/31'23
+$1,250.00
+^
+NVisa
+TCCard
+DRewards card
+L5,000.00
+$-320.50
+^
+!Clear:AutoSwitch
+!Type:Memorized
+KP
+T-45.00
+PCity Power
+MMonthly bill
+LUtilities:Electric
+^
+KC
+T-100.00
+PRent Co
+SHousing:Rent
+ERent share
+$-80.00
+S[Visa]
+$-20.00
+^
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/ 5'24
+U-1,234.56
+T-1,234.56
+CX
+N1001
+PLandlord Inc
+MJanuary rent
+A123 Main St
+ASpringfield
+LHousing:Rent/Business
+^
+D1/15'24
+T2,500.00
+C*
+PAcme Corp
+LSalary
+^
+D1/20'24
+T-150.00
+PMarket
+MWeekly shop
+SGroceries
+EFood
+%80%
+$-120.00
+S[Visa]/Business
+ECard payment
+$-30.00
+^
+!Account
+NVisa
+TCCard
+^
+!Type:CCard
+D12/30/99
+T-9.99
+POld Store
+LShopping:Vacation
+^
+`
+
+func TestFormatQIFRoundTrip(t *testing.T) {
+	original := parseQIF(roundTripFixture)
+	if len(original.Warnings) > 0 {
+		t.Fatalf("fixture has warnings: %v", original.Warnings)
+	}
+	content := formatQIF(original, nil)
+	reparsed := parseQIF(content)
+	if len(reparsed.Warnings) > 0 {
+		t.Fatalf("output has warnings: %v", reparsed.Warnings)
+	}
+
+	// Guard against a fixture that fails to parse at all
+	if len(original.Accounts) != 2 || len(original.Accounts[0].Transactions) != 3 || len(original.Memorized) != 2 {
+		t.Fatalf("fixture parsed to %d accounts and %d memorized records", len(original.Accounts), len(original.Memorized))
+	}
+
+	if len(reparsed.Tags) != len(original.Tags) {
+		t.Fatalf("tags: got %d, want %d", len(reparsed.Tags), len(original.Tags))
+	}
+	for i, want := range original.Tags {
+		if got := reparsed.Tags[i]; *got != *want {
+			t.Errorf("tag %d: got %+v, want %+v", i, *got, *want)
+		}
+	}
+
+	if len(reparsed.Classes) != len(original.Classes) {
+		t.Fatalf("classes: got %d, want %d", len(reparsed.Classes), len(original.Classes))
+	}
+	for i, want := range original.Classes {
+		if got := reparsed.Classes[i]; *got != *want {
+			t.Errorf("class %d: got %+v, want %+v", i, *got, *want)
+		}
+	}
+
+	if len(reparsed.Categories) != len(original.Categories) {
+		t.Fatalf("categories: got %d, want %d", len(reparsed.Categories), len(original.Categories))
+	}
+	for i, want := range original.Categories {
+		got := reparsed.Categories[i]
+		if got.Name != want.Name || got.Description != want.Description || got.Income != want.Income ||
+			got.Expense != want.Expense || got.TaxRelated != want.TaxRelated || got.TaxSchedule != want.TaxSchedule {
+			t.Errorf("category %d: got %+v, want %+v", i, *got, *want)
+		}
+		if len(got.Budget) != len(want.Budget) {
+			t.Errorf("category %s: got %d budget lines, want %d", want.Name, len(got.Budget), len(want.Budget))
+			continue
+		}
+		for j := range want.Budget {
+			if got.Budget[j] != want.Budget[j] {
+				t.Errorf("category %s budget %d: got %s, want %s", want.Name, j, got.Budget[j], want.Budget[j])
+			}
+		}
+	}
+
+	if len(reparsed.Accounts) != len(original.Accounts) {
+		t.Fatalf("accounts: got %d, want %d", len(reparsed.Accounts), len(original.Accounts))
+	}
+	for i, want := range original.Accounts {
+		got := reparsed.Accounts[i]
+		if got.Name != want.Name || got.Type != want.Type || got.Description != want.Description ||
+			got.CreditLimit != want.CreditLimit || got.Balance != want.Balance || got.BalanceDate != want.BalanceDate {
+			t.Errorf("account %d: got %s/%s/%s/%s/%s/%s, want %s/%s/%s/%s/%s/%s", i,
+				got.Name, got.Type, got.Description, got.CreditLimit, got.Balance, got.BalanceDate,
+				want.Name, want.Type, want.Description, want.CreditLimit, want.Balance, want.BalanceDate)
+		}
+		if len(got.Transactions) != len(want.Transactions) {
+			t.Errorf("account %s: got %d transactions, want %d", want.Name, len(got.Transactions), len(want.Transactions))
+			continue
+		}
+		for j := range want.Transactions {
+			compareTransactions(t, want.Name, got.Transactions[j], want.Transactions[j])
+		}
+	}
+
+	if len(reparsed.Memorized) != len(original.Memorized) {
+		t.Fatalf("memorized: got %d, want %d", len(reparsed.Memorized), len(original.Memorized))
+	}
+	for i, want := range original.Memorized {
+		got := reparsed.Memorized[i]
+		if got.Kind != want.Kind {
+			t.Errorf("memorized %d: got kind %q, want %q", i, got.Kind, want.Kind)
+		}
+		compareTransactions(t, "memorized", got.qifTransaction, want.qifTransaction)
+	}
+
+	// Writing the parsed output again must not change it
+	if again := formatQIF(reparsed, nil); again != content {
+		t.Errorf("second write differs from the first:\n%s\n---\n%s", content, again)
+	}
+}
+
+func compareTransactions(t *testing.T, account string, got *qifTransaction, want *qifTransaction) {
+	t.Helper()
+	where := account + " " + want.RawDate + " " + want.Payee
+	if !got.Date.Equal(want.Date) {
+		t.Errorf("%s: date %v, want %v", where, got.Date, want.Date)
+	}
+	if got.RawDate != want.RawDate {
+		t.Errorf("%s: raw date %q, want %q", where, got.RawDate, want.RawDate)
+	}
+	if got.Amount != want.Amount {
+		t.Errorf("%s: amount %s, want %s", where, got.Amount, want.Amount)
+	}
+	if got.Cleared != want.Cleared || got.Number != want.Number || got.Payee != want.Payee ||
+		got.Memo != want.Memo || got.Category != want.Category {
+		t.Errorf("%s: got %q/%q/%q/%q/%q, want %q/%q/%q/%q/%q", where,
+			got.Cleared, got.Number, got.Payee, got.Memo, got.Category,
+			want.Cleared, want.Number, want.Payee, want.Memo, want.Category)
+	}
+	if strings.Join(got.Address, "|") != strings.Join(want.Address, "|") {
+		t.Errorf("%s: address %q, want %q", where, got.Address, want.Address)
+	}
+	if len(got.Splits) != len(want.Splits) {
+		t.Errorf("%s: %d splits, want %d", where, len(got.Splits), len(want.Splits))
+		return
+	}
+	for i, split := range want.Splits {
+		if *got.Splits[i] != *split {
+			t.Errorf("%s: split %d is %+v, want %+v", where, i, *got.Splits[i], *split)
+		}
+	}
+}
+
+func TestFormatQIFAccountWithoutType(t *testing.T) {
+	original := parseQIF("!Account\nNWallet\n^\n!Type:Cash\nD3/ 5'24\nT-5.00\nPCoffee\n^\n")
+	original.Accounts[0].Type = ""
+	reparsed := parseQIF(formatQIF(original, nil))
+	if len(reparsed.Accounts) != 1 {
+		t.Fatalf("got %d accounts, want 1", len(reparsed.Accounts))
+	}
+	account := reparsed.Accounts[0]
+	if account.Type != "Bank" {
+		t.Errorf("account type %q, want Bank", account.Type)
+	}
+	if len(account.Transactions) != 1 || account.Transactions[0].Amount != -500 {
+		t.Errorf("transactions not read back: %v", account.Transactions)
+	}
+}
+
+func TestFormatQIFInvestmentRoundTrip(t *testing.T) {
+	original := parseQIF(`!Type:Security
+NInternational Business Machines
+SIBM
+TStock
+GGrowth
+^
+!Account
+NBrokerage
+TInvst
+^
+!Type:Invst
+D1/ 5'22
+NBuyX
+YInternational Business Machines
+I100.125
+Q10
+U1,006.25
+T1,006.25
+O5.00
+CR
+PBroker
+MFirst lot
+L[Checking]
+$1,006.25
+^
+D8/ 1'23
+NStkSplit
+YInternational Business Machines
+Q20
+^
+!Type:Prices
+"IBM",141 1/4,"9/30'23"
+^
+`)
+	if len(original.Warnings) > 0 {
+		t.Fatalf("fixture has warnings: %v", original.Warnings)
+	}
+	content := formatQIF(original, map[string]string{"Checking": "Main Checking"})
+	reparsed := parseQIF(content)
+	if len(reparsed.Warnings) > 0 {
+		t.Fatalf("output has warnings: %v", reparsed.Warnings)
+	}
+	if got, want := qifRecordCounts(reparsed), qifRecordCounts(original); got != want {
+		t.Fatalf("output holds %s, want %s", got, want)
+	}
+
+	if *reparsed.Securities[0] != *original.Securities[0] {
+		t.Errorf("security %+v, want %+v", *reparsed.Securities[0], *original.Securities[0])
+	}
+	if got, want := *reparsed.Prices[0], *original.Prices[0]; got != want || want.Price != 141.25 {
+		t.Errorf("price %+v, want %+v at 141.25", got, want)
+	}
+
+	account := reparsed.Accounts[0]
+	if account.Name != "Brokerage" || account.Type != "Invst" || len(account.Investments) != 2 {
+		t.Fatalf("account %s (%s) with %d investment transactions", account.Name, account.Type, len(account.Investments))
+	}
+	for i, want := range original.Accounts[0].Investments {
+		got := *account.Investments[i]
+		expected := *want
+		if i == 0 {
+			// Transfers are renamed through the account mapping
+			expected.Category = "[Main Checking]"
+		}
+		// The date is rewritten the way Quicken writes it
+		expected.RawDate = formatQIFDate(want.Date)
+		if got != expected {
+			t.Errorf("investment %d is %+v, want %+v", i, got, expected)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recurringFrequency is a period a series can repeat at. Intervals between
+// payments count as matching when they fall within Min and Max days.
+type recurringFrequency struct {
+	Name   string
+	Min    int
+	Max    int
+	Months int
+	Days   int
+}
+
+var recurringFrequencies = []recurringFrequency{
+	{Name: "weekly", Min: 6, Max: 8, Days: 7},
+	{Name: "biweekly", Min: 13, Max: 15, Days: 14},
+	{Name: "monthly", Min: 27, Max: 34, Months: 1},
+	{Name: "quarterly", Min: 85, Max: 97, Months: 3},
+	{Name: "annual", Min: 355, Max: 375, Months: 12},
+}
+
+// next returns the date one period after date.
+func (f recurringFrequency) next(date time.Time) time.Time {
+	return date.AddDate(0, f.Months, f.Days)
+}
+
+// recurringSeries is a run of transactions with the same payee, similar
+// amounts and a regular interval.
+type recurringSeries struct {
+	Payee        string
+	Frequency    recurringFrequency
+	Transactions []*qifTransaction
+	Accounts     []string
+	Category     string
+	Average      money
+	Min          money
+	Max          money
+	Next         time.Time
+	Stopped      bool
+}
+
+// runRecurring is the recurring subcommand: it lists subscriptions and other
+// regular payments.
+func runRecurring(args []string) {
+	recurringCmd := flag.NewFlagSet("recurring", flag.ExitOnError)
+	var inputFile inputFileList
+	recurringCmd.Var(&inputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
+	outputFile := recurringCmd.String("outputfile", "recurring.csv", "recurring series CSV")
+	tolerance := recurringCmd.Float64("tolerance", 10, "percent an amount may differ from the series and still belong to it")
+	minOccurrences := recurringCmd.Int("min-occurrences", 3, "transactions needed before a series counts as recurring")
+	asOf := recurringCmd.String("asof", "", "date to judge stopped series against (YYYY-MM-DD, default the last transaction date)")
+	recurringCmd.Parse(args)
+	fmt.Println("subcommand 'recurring'")
+	fmt.Println("	inputfile:", inputFile.String())
+	fmt.Println("	outputfile:", *outputFile)
+	fmt.Println("	tolerance:", *tolerance)
+	fmt.Println("	min-occurrences:", *minOccurrences)
+	fmt.Println("	asof:", *asOf)
+
+	var asOfDate time.Time
+	if *asOf != "" {
+		var err error
+		asOfDate, err = time.Parse("2006-01-02", *asOf)
+		if err != nil {
+			fmt.Println("invalid -asof date:", *asOf)
+			os.Exit(1)
+		}
+	}
+	if *minOccurrences < 2 {
+		*minOccurrences = 2
+	}
+
+	qif, err := loadQIFFiles(inputFile, nil)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+
+	series := findRecurring(qif, *tolerance, *minOccurrences, asOfDate)
+	err = writeRecurringCSV(*outputFile, series)
+	if err != nil {
+		fmt.Println("Error writing recurring series:", err)
+		return
+	}
+	stopped := 0
+	for _, s := range series {
+		if s.Stopped {
+			stopped++
+		}
+	}
+	fmt.Printf("Recurring series: %d (%d stopped)\n", len(series), stopped)
+}
+
+// findRecurring groups transactions by normalized payee, splits each group
+// into runs of similar amounts, and keeps the runs whose intervals mostly
+// fit one of the recurring frequencies. Transfers are left out. A series has
+// stopped when asOf is more than half a period past its next expected date.
+func findRecurring(qif *qifFile, tolerance float64, minOccurrences int, asOf time.Time) []*recurringSeries {
+	type entry struct {
+		account string
+		t       *qifTransaction
+	}
+	groups := make(map[string][]entry)
+	var keys []string
+	var latest time.Time
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			if t.Date.IsZero() || strings.HasPrefix(t.Category, "[") {
+				continue
+			}
+			key := normalizePayee(t.Payee)
+			if key == "" {
+				continue
+			}
+			if groups[key] == nil {
+				keys = append(keys, key)
+			}
+			groups[key] = append(groups[key], entry{account.Name, t})
+			if t.Date.After(latest) {
+				latest = t.Date
+			}
+		}
+	}
+	if asOf.IsZero() {
+		asOf = latest
+	}
+	sort.Strings(keys)
+
+	var result []*recurringSeries
+	for _, key := range keys {
+		entries := groups[key]
+		// Runs of similar amounts, compared with the first amount of the run
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].t.Amount < entries[j].t.Amount
+		})
+		var runs [][]entry
+		for _, e := range entries {
+			if n := len(runs); n > 0 {
+				base := runs[n-1][0].t.Amount.Abs()
+				if float64((e.t.Amount - runs[n-1][0].t.Amount).Abs()) <= float64(base)*tolerance/100 {
+					runs[n-1] = append(runs[n-1], e)
+					continue
+				}
+			}
+			runs = append(runs, []entry{e})
+		}
+
+		for _, run := range runs {
+			if len(run) < minOccurrences {
+				continue
+			}
+			sort.SliceStable(run, func(i, j int) bool {
+				return run[i].t.Date.Before(run[j].t.Date)
+			})
+			var intervals []int
+			for i := 1; i < len(run); i++ {
+				intervals = append(intervals, int(run[i].t.Date.Sub(run[i-1].t.Date).Hours()/24))
+			}
+			frequency, ok := matchFrequency(intervals)
+			if !ok {
+				continue
+			}
+
+			s := &recurringSeries{Payee: run[len(run)-1].t.Payee, Frequency: frequency}
+			accounts := make(map[string]bool)
+			categories := make(map[string]int)
+			var total money
+			for i, e := range run {
+				s.Transactions = append(s.Transactions, e.t)
+				if !accounts[e.account] {
+					accounts[e.account] = true
+					s.Accounts = append(s.Accounts, e.account)
+				}
+				category, _ := splitCategoryAndTag(e.t.Category)
+				categories[category]++
+				total += e.t.Amount
+				if i == 0 || e.t.Amount < s.Min {
+					s.Min = e.t.Amount
+				}
+				if i == 0 || e.t.Amount > s.Max {
+					s.Max = e.t.Amount
+				}
+			}
+			for category, count := range categories {
+				if count > categories[s.Category] || (count == categories[s.Category] && category < s.Category) {
+					s.Category = category
+				}
+			}
+			s.Average = total / money(len(run))
+			last := run[len(run)-1].t.Date
+			s.Next = frequency.next(last)
+			grace := (frequency.Min + frequency.Max) / 4
+			s.Stopped = asOf.After(s.Next.AddDate(0, 0, grace))
+			result = append(result, s)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Stopped != result[j].Stopped {
+			return !result[i].Stopped
+		}
+		return result[i].Next.Before(result[j].Next)
+	})
+	return result
+}
+
+// matchFrequency picks the frequency that at least three quarters of the
+// intervals fit. An interval of twice the period, from a missed payment,
+// still fits, but at least half must be a single period so a biweekly
+// series is not taken for a weekly one.
+func matchFrequency(intervals []int) (recurringFrequency, bool) {
+	for _, frequency := range recurringFrequencies {
+		fits, single := 0, 0
+		for _, days := range intervals {
+			if days >= frequency.Min && days <= frequency.Max {
+				fits++
+				single++
+			} else if days >= frequency.Min*2 && days <= frequency.Max*2 {
+				fits++
+			}
+		}
+		if fits*4 >= len(intervals)*3 && single*2 >= len(intervals) {
+			return frequency, true
+		}
+	}
+	return recurringFrequency{}, false
+}
+
+func writeRecurringCSV(outputFileName string, series []*recurringSeries) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Payee", "Frequency", "Occurrences", "First Date", "Last Date", "Next Expected", "Status", "Average Amount", "Min Amount", "Max Amount", "Category", "Accounts"})
+	for _, s := range series {
+		status := "active"
+		if s.Stopped {
+			status = "stopped"
+		}
+		writer.Write([]string{
+			s.Payee,
+			s.Frequency.Name,
+			fmt.Sprint(len(s.Transactions)),
+			s.Transactions[0].Date.Format("2006-01-02"),
+			s.Transactions[len(s.Transactions)-1].Date.Format("2006-01-02"),
+			s.Next.Format("2006-01-02"),
+			status,
+			s.Average.String(),
+			s.Min.String(),
+			s.Max.String(),
+			s.Category,
+			strings.Join(s.Accounts, ";"),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
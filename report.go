@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// reportRow is one category line of the report with an amount per period.
+type reportRow struct {
+	Category string
+	Amounts  map[string]money
+}
+
+// reportSection holds the income or expense categories of a report.
+type reportSection struct {
+	Name string
+	Rows []*reportRow
+	// Total is the sum of the top level categories per period
+	Total map[string]money
+}
+
+// runReport is the report subcommand: it totals transactions by category
+// and month or year.
+func runReport(args []string) {
+	reportCmd := flag.NewFlagSet("report", flag.ExitOnError)
+	var inputFile inputFileList
+	reportCmd.Var(&inputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
+	outputFile := reportCmd.String("outputfile", "", "output file (default report.csv or report.md)")
+	format := reportCmd.String("format", "csv", "output format: csv or markdown")
+	period := reportCmd.String("period", "month", "column period: month or year")
+	categoryMapFile := reportCmd.String("categorymap", "", "category mapping file")
+	from := reportCmd.String("from", "", "only transactions on or after this date (YYYY-MM-DD)")
+	to := reportCmd.String("to", "", "only transactions on or before this date (YYYY-MM-DD)")
+	accounts := reportCmd.String("accounts", "", "only these accounts (comma separated names, types or globs)")
+	reportCmd.Parse(args)
+	fmt.Println("subcommand 'report'")
+	fmt.Println("	inputfile:", inputFile.String())
+	fmt.Println("	outputfile:", *outputFile)
+	fmt.Println("	format:", *format)
+	fmt.Println("	period:", *period)
+	fmt.Println("	categorymap:", *categoryMapFile)
+	fmt.Println("	from:", *from)
+	fmt.Println("	to:", *to)
+	fmt.Println("	accounts:", *accounts)
+
+	if *format != "csv" && *format != "markdown" {
+		fmt.Println("unknown report format:", *format, "(expected csv or markdown)")
+		os.Exit(1)
+	}
+	if *period != "month" && *period != "year" {
+		fmt.Println("unknown report period:", *period, "(expected month or year)")
+		os.Exit(1)
+	}
+	filter, err := newTransactionFilter(*from, *to, *accounts, "", "", "", false, "")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *outputFile == "" {
+		*outputFile = "report.csv"
+		if *format == "markdown" {
+			*outputFile = "report.md"
+		}
+	}
+
+	var categoryMapping map[string]string
+	if *categoryMapFile != "" {
+		categoryMapping, err = loadMapping(*categoryMapFile)
+		if err != nil {
+			fmt.Println("Error loading mapping:", err)
+			return
+		}
+	}
+
+	qif, err := loadQIFFiles(inputFile, nil)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+	filterTransactions(qif, filter)
+	applyTransactionMappings(qif, nil, categoryMapping, nil)
+
+	periods, sections := buildCategoryReport(qif, *period)
+
+	file, err := os.Create(*outputFile)
+	if err != nil {
+		fmt.Println("Error creating file:", err)
+		return
+	}
+	defer file.Close()
+	if *format == "markdown" {
+		err = writeReportMarkdown(file, periods, sections)
+	} else {
+		err = writeReportCSV(file, periods, sections)
+	}
+	if err != nil {
+		fmt.Println("Error writing report:", err)
+		return
+	}
+	fmt.Println("Report written:", *outputFile, "periods:", len(periods))
+}
+
+// reportPeriod is the column key for a date.
+func reportPeriod(date time.Time, period string) string {
+	if period == "year" {
+		return date.Format("2006")
+	}
+	return date.Format("2006-01")
+}
+
+// uncategorizedName is used for transactions and splits without a category.
+const uncategorizedName = "Uncategorized"
+
+// buildCategoryReport totals transactions, or their splits, by category and
+// period. Transfers between accounts are left out. Each category also adds
+// to its parents in the "Parent:Child" hierarchy. A category is income when
+// the category list marks it so, or otherwise when its total is positive.
+// The periods run without gaps from the first transaction to the last.
+func buildCategoryReport(qif *qifFile, period string) ([]string, []*reportSection) {
+	income := make(map[string]bool)
+	known := make(map[string]bool)
+	for _, category := range qif.Categories {
+		known[category.Name] = true
+		income[category.Name] = category.Income
+	}
+
+	totals := make(map[string]map[string]money)
+	net := make(map[string]money)
+	var first, last time.Time
+	add := func(value string, amount money, date time.Time) {
+		category, _ := splitCategoryAndTag(value)
+		if strings.HasPrefix(category, "[") {
+			return
+		}
+		if category == "" {
+			category = uncategorizedName
+		}
+		if totals[category] == nil {
+			totals[category] = make(map[string]money)
+		}
+		totals[category][reportPeriod(date, period)] += amount
+		net[category] += amount
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if date.After(last) {
+			last = date
+		}
+	}
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			if t.Date.IsZero() {
+				continue
+			}
+			if len(t.Splits) == 0 {
+				add(t.Category, t.Amount, t.Date)
+			}
+			for _, split := range t.Splits {
+				add(split.Category, split.Amount, t.Date)
+			}
+		}
+	}
+
+	var periods []string
+	if !first.IsZero() {
+		step := func(d time.Time) time.Time { return d.AddDate(0, 1, 0) }
+		start := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if period == "year" {
+			step = func(d time.Time) time.Time { return d.AddDate(1, 0, 0) }
+			start = time.Date(first.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		for d := start; !d.After(last); d = step(d) {
+			periods = append(periods, reportPeriod(d, period))
+		}
+	}
+
+	sections := []*reportSection{
+		{Name: "Income", Total: make(map[string]money)},
+		{Name: "Expenses", Total: make(map[string]money)},
+	}
+	rows := []map[string]*reportRow{make(map[string]*reportRow), make(map[string]*reportRow)}
+	for category, amounts := range totals {
+		section := 1
+		if (known[category] && income[category]) || (!known[category] && net[category] > 0) {
+			section = 0
+		}
+		parts := strings.Split(category, ":")
+		for depth := 1; depth <= len(parts); depth++ {
+			name := strings.Join(parts[:depth], ":")
+			row := rows[section][name]
+			if row == nil {
+				row = &reportRow{Category: name, Amounts: make(map[string]money)}
+				rows[section][name] = row
+			}
+			for key, amount := range amounts {
+				row.Amounts[key] += amount
+			}
+		}
+		for key, amount := range amounts {
+			sections[section].Total[key] += amount
+		}
+	}
+	for i, section := range sections {
+		for _, row := range rows[i] {
+			section.Rows = append(section.Rows, row)
+		}
+		sort.Slice(section.Rows, func(a, b int) bool {
+			return section.Rows[a].Category < section.Rows[b].Category
+		})
+	}
+	return periods, sections
+}
+
+// reportLine formats a label, the period amounts, the total and the average
+// per period.
+func reportLine(label string, periods []string, amounts map[string]money) []string {
+	line := []string{label}
+	var total money
+	for _, key := range periods {
+		line = append(line, amounts[key].String())
+		total += amounts[key]
+	}
+	average := money(0)
+	if len(periods) > 0 {
+		average = total / money(len(periods))
+	}
+	return append(line, total.String(), average.String())
+}
+
+// reportNet is income plus expenses per period.
+func reportNet(sections []*reportSection) map[string]money {
+	net := make(map[string]money)
+	for _, section := range sections {
+		for key, amount := range section.Total {
+			net[key] += amount
+		}
+	}
+	return net
+}
+
+func writeReportCSV(out io.Writer, periods []string, sections []*reportSection) error {
+	writer := csv.NewWriter(out)
+	header := append([]string{"Section", "Category"}, periods...)
+	writer.Write(append(header, "Total", "Average"))
+	for _, section := range sections {
+		for _, row := range section.Rows {
+			writer.Write(append([]string{section.Name}, reportLine(row.Category, periods, row.Amounts)...))
+		}
+		writer.Write(append([]string{section.Name}, reportLine("Total "+section.Name, periods, section.Total)...))
+	}
+	writer.Write(append([]string{""}, reportLine("Net", periods, reportNet(sections))...))
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeReportMarkdown(out io.Writer, periods []string, sections []*reportSection) error {
+	table := func(lines [][]string) error {
+		header := append([]string{"Category"}, periods...)
+		header = append(header, "Total", "Average")
+		alignment := []string{"---"}
+		for range header[1:] {
+			alignment = append(alignment, "---:")
+		}
+		for _, line := range append([][]string{header, alignment}, lines...) {
+			if _, err := fmt.Fprintf(out, "| %s |\n", strings.Join(line, " | ")); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintln(out)
+		return err
+	}
+
+	for _, section := range sections {
+		if _, err := fmt.Fprintf(out, "## %s\n\n", section.Name); err != nil {
+			return err
+		}
+		var lines [][]string
+		for _, row := range section.Rows {
+			// Indent child categories under their parent
+			depth := strings.Count(row.Category, ":")
+			label := strings.Repeat("&nbsp;&nbsp;", depth) + strings.ReplaceAll(row.Category, "|", "\\|")
+			lines = append(lines, reportLine(label, periods, row.Amounts))
+		}
+		total := reportLine("Total "+section.Name, periods, section.Total)
+		for i := range total {
+			total[i] = "**" + total[i] + "**"
+		}
+		if err := table(append(lines, total)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprint(out, "## Net\n\n"); err != nil {
+		return err
+	}
+	return table([][]string{reportLine("Net", periods, reportNet(sections))})
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchemaVersion is stored in PRAGMA user_version.
+const sqliteSchemaVersion = 1
+
+// sqliteSchema creates the tables on first use. Amounts are stored as integer
+// cents; transaction_view joins everything back together with amounts in
+// dollars. A transaction's fingerprint is the same one -since-state uses,
+// taken from the account name in the QIF file before mapping, and it is
+// unique per account so appending a later export skips the transactions
+// already loaded.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS accounts (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	type TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	credit_limit TEXT NOT NULL DEFAULT '',
+	balance TEXT NOT NULL DEFAULT '',
+	balance_date TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS categories (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT '',
+	income INTEGER NOT NULL DEFAULT 0,
+	expense INTEGER NOT NULL DEFAULT 0,
+	tax_related INTEGER NOT NULL DEFAULT 0,
+	tax_schedule TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS tags (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS payees (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS transactions (
+	id INTEGER PRIMARY KEY,
+	account_id INTEGER NOT NULL REFERENCES accounts(id),
+	fingerprint TEXT NOT NULL,
+	date TEXT NOT NULL,
+	raw_date TEXT NOT NULL DEFAULT '',
+	amount_cents INTEGER NOT NULL,
+	cleared TEXT NOT NULL DEFAULT '',
+	number TEXT NOT NULL DEFAULT '',
+	payee_id INTEGER REFERENCES payees(id),
+	category_id INTEGER REFERENCES categories(id),
+	transfer_account_id INTEGER REFERENCES accounts(id),
+	tag_id INTEGER REFERENCES tags(id),
+	memo TEXT NOT NULL DEFAULT '',
+	address TEXT NOT NULL DEFAULT '',
+	original_payee TEXT NOT NULL DEFAULT '',
+	original_category TEXT NOT NULL DEFAULT '',
+	source TEXT NOT NULL DEFAULT '',
+	UNIQUE (account_id, fingerprint)
+);
+CREATE TABLE IF NOT EXISTS splits (
+	id INTEGER PRIMARY KEY,
+	transaction_id INTEGER NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	category_id INTEGER REFERENCES categories(id),
+	transfer_account_id INTEGER REFERENCES accounts(id),
+	tag_id INTEGER REFERENCES tags(id),
+	memo TEXT NOT NULL DEFAULT '',
+	amount_cents INTEGER NOT NULL,
+	percent TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS transactions_date ON transactions(date);
+CREATE INDEX IF NOT EXISTS transactions_account_date ON transactions(account_id, date);
+CREATE INDEX IF NOT EXISTS transactions_payee ON transactions(payee_id);
+CREATE INDEX IF NOT EXISTS transactions_category ON transactions(category_id);
+CREATE INDEX IF NOT EXISTS splits_transaction ON splits(transaction_id);
+CREATE INDEX IF NOT EXISTS splits_category ON splits(category_id);
+CREATE VIEW IF NOT EXISTS transaction_view AS
+SELECT t.id, a.name AS account, t.date, t.amount_cents / 100.0 AS amount,
+	t.cleared, t.number, p.name AS payee, c.name AS category,
+	x.name AS transfer_account, g.name AS tag, t.memo, t.source
+FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+LEFT JOIN payees p ON p.id = t.payee_id
+LEFT JOIN categories c ON c.id = t.category_id
+LEFT JOIN accounts x ON x.id = t.transfer_account_id
+LEFT JOIN tags g ON g.id = t.tag_id;
+`
+
+// sqliteWriter inserts rows and remembers the ids of the names it has
+// already looked up.
+type sqliteWriter struct {
+	tx  *sql.Tx
+	ids map[string]int64
+}
+
+// id returns the row id for name in a table with a unique name column,
+// inserting the row if it is not there yet. Empty names have no row.
+func (w *sqliteWriter) id(table string, name string) (sql.NullInt64, error) {
+	if name == "" {
+		return sql.NullInt64{}, nil
+	}
+	key := table + "\x00" + name
+	if id, ok := w.ids[key]; ok {
+		return sql.NullInt64{Int64: id, Valid: true}, nil
+	}
+	_, err := w.tx.Exec("INSERT INTO "+table+" (name) VALUES (?) ON CONFLICT(name) DO NOTHING", name)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	var id int64
+	err = w.tx.QueryRow("SELECT id FROM "+table+" WHERE name = ?", name).Scan(&id)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	w.ids[key] = id
+	return sql.NullInt64{Int64: id, Valid: true}, nil
+}
+
+// categoryIDs resolves a transaction or split category into its category or
+// transfer account and its tag.
+func (w *sqliteWriter) categoryIDs(value string, accountMapping map[string]string) (category, transfer, tag sql.NullInt64, err error) {
+	name, tagName := splitCategoryAndTag(value)
+	if strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") {
+		transfer, err = w.id("accounts", ofxAccountName(name[1:len(name)-1], accountMapping))
+	} else {
+		category, err = w.id("categories", name)
+	}
+	if err != nil {
+		return
+	}
+	tag, err = w.id("tags", tagName)
+	return
+}
+
+// writeSQLiteFile creates the database or adds to an existing one. Account,
+// category and tag details are updated from the file being exported.
+func writeSQLiteFile(qif *qifFile, accountMapping map[string]string, outputFileName string) error {
+	db, err := sql.Open("sqlite", "file:"+outputFileName+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var version int
+	err = db.QueryRow("PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return err
+	}
+	if version > sqliteSchemaVersion {
+		return fmt.Errorf("%s has schema version %d, this tool writes version %d", outputFileName, version, sqliteSchemaVersion)
+	}
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("PRAGMA user_version = %d", sqliteSchemaVersion))
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	w := &sqliteWriter{tx: tx, ids: make(map[string]int64)}
+
+	for _, c := range qif.Categories {
+		_, err = tx.Exec(`INSERT INTO categories (name, description, income, expense, tax_related, tax_schedule) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET description = excluded.description, income = excluded.income,
+			expense = excluded.expense, tax_related = excluded.tax_related, tax_schedule = excluded.tax_schedule`,
+			c.Name, c.Description, c.Income, c.Expense, c.TaxRelated, c.TaxSchedule)
+		if err != nil {
+			return err
+		}
+	}
+	for _, tag := range qif.Tags {
+		_, err = tx.Exec("INSERT INTO tags (name, description) VALUES (?, ?) ON CONFLICT(name) DO UPDATE SET description = excluded.description",
+			tag.Name, tag.Description)
+		if err != nil {
+			return err
+		}
+	}
+
+	added, skipped := 0, 0
+	for _, account := range qif.Accounts {
+		name := ofxAccountName(account.Name, accountMapping)
+		if name == "" {
+			// An account record without an N line; accounts.name is required
+			name = strings.TrimSpace("Unnamed " + account.Type)
+		}
+		_, err = tx.Exec(`INSERT INTO accounts (name, type, description, credit_limit, balance, balance_date) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET type = excluded.type, description = excluded.description,
+			credit_limit = excluded.credit_limit, balance = excluded.balance, balance_date = excluded.balance_date`,
+			name, account.Type, account.Description, account.CreditLimit, account.Balance, account.BalanceDate)
+		if err != nil {
+			return err
+		}
+		accountID, err := w.id("accounts", name)
+		if err != nil {
+			return err
+		}
+
+		fingerprints := transactionFingerprints(account.Name, account.Transactions)
+		for i, t := range account.Transactions {
+			payeeID, err := w.id("payees", t.Payee)
+			if err != nil {
+				return err
+			}
+			categoryID, transferID, tagID, err := w.categoryIDs(t.Category, accountMapping)
+			if err != nil {
+				return err
+			}
+			date := ""
+			if !t.Date.IsZero() {
+				date = t.Date.Format("2006-01-02")
+			}
+			result, err := tx.Exec(`INSERT INTO transactions (account_id, fingerprint, date, raw_date, amount_cents, cleared, number,
+				payee_id, category_id, transfer_account_id, tag_id, memo, address, original_payee, original_category, source)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(account_id, fingerprint) DO NOTHING`,
+				accountID, fingerprints[i], date, t.RawDate, int64(t.Amount), t.Cleared, t.Number,
+				payeeID, categoryID, transferID, tagID, t.Memo, strings.Join(t.Address, "\n"),
+				t.OriginalPayee, t.OriginalCategory, t.Source)
+			if err != nil {
+				return err
+			}
+			if rows, _ := result.RowsAffected(); rows == 0 {
+				skipped++
+				continue
+			}
+			added++
+
+			transactionID, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			for position, split := range t.Splits {
+				categoryID, transferID, tagID, err := w.categoryIDs(split.Category, accountMapping)
+				if err != nil {
+					return err
+				}
+				_, err = tx.Exec(`INSERT INTO splits (transaction_id, position, category_id, transfer_account_id, tag_id, memo, amount_cents, percent)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+					transactionID, position+1, categoryID, transferID, tagID, split.Memo, int64(split.Amount), split.Percent)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	fmt.Println("SQLite database written:", outputFileName, "transactions added:", added, "already present:", skipped)
+	return nil
+}
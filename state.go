@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// exportState is the -since-state file. It remembers, per account, the latest
+// transaction date exported and the fingerprints of recently exported
+// transactions.
+type exportState struct {
+	Version  int                            `json:"version"`
+	Accounts map[string]*accountExportState `json:"accounts"`
+}
+
+type accountExportState struct {
+	LastExported string            `json:"lastExported"`
+	Fingerprints map[string]string `json:"fingerprints"`
+}
+
+// loadExportState reads the state file. A missing file is an empty state so
+// the first run exports everything.
+func loadExportState(fileName string) (*exportState, error) {
+	state := &exportState{Version: 1, Accounts: make(map[string]*accountExportState)}
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %s", fileName, err)
+	}
+	if state.Accounts == nil {
+		state.Accounts = make(map[string]*accountExportState)
+	}
+	return state, nil
+}
+
+func saveExportState(fileName string, state *exportState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0644)
+}
+
+// transactionFingerprints returns a fingerprint per transaction built from the
+// date, amount and check number. Payee, memo and category are left out so
+// renaming or recategorizing an old entry in Quicken does not make it look
+// new. Identical transactions are numbered by occurrence, which does not
+// depend on the order they appear in.
+func transactionFingerprints(accountName string, transactions []*qifTransaction) []string {
+	seen := make(map[string]int)
+	fingerprints := make([]string, len(transactions))
+	for i, t := range transactions {
+		hash := sha1.Sum([]byte(accountName + "|" + t.Date.Format("2006-01-02") + "|" + t.Amount.String() + "|" + t.Number))
+		key := hex.EncodeToString(hash[:8])
+		seen[key]++
+		fingerprints[i] = fmt.Sprintf("%s#%d", key, seen[key])
+	}
+	return fingerprints
+}
+
+// applyExportState removes transactions already recorded in the state and
+// returns how many were skipped. Transactions dated more than graceDays before
+// the account's last export are treated as already exported, so edits to old
+// entries are not sent again while late-posting bank downloads still are.
+func applyExportState(qif *qifFile, state *exportState, graceDays int) int {
+	skipped := 0
+	for _, account := range qif.Accounts {
+		accountState := state.Accounts[account.Name]
+		if accountState == nil || len(account.Transactions) == 0 {
+			continue
+		}
+		cutoff := time.Time{}
+		if lastExported, err := time.Parse("2006-01-02", accountState.LastExported); err == nil {
+			cutoff = lastExported.AddDate(0, 0, -graceDays)
+		}
+
+		var kept []*qifTransaction
+		for i, fingerprint := range transactionFingerprints(account.Name, account.Transactions) {
+			t := account.Transactions[i]
+			if _, ok := accountState.Fingerprints[fingerprint]; ok || t.Date.Before(cutoff) {
+				skipped++
+				continue
+			}
+			kept = append(kept, t)
+		}
+		account.Transactions = kept
+	}
+	return skipped
+}
+
+// accountsWithoutNewTransactions returns the accounts exported by a previous
+// run that have nothing left to export this time.
+func accountsWithoutNewTransactions(qif *qifFile, state *exportState) []string {
+	var names []string
+	for _, account := range qif.Accounts {
+		if state.Accounts[account.Name] != nil && len(account.Transactions) == 0 {
+			names = append(names, account.Name)
+		}
+	}
+	return names
+}
+
+// recordExportState adds the exported transactions to the state and prunes
+// fingerprints that have fallen out of the grace window.
+func recordExportState(qif *qifFile, state *exportState, graceDays int) {
+	for _, account := range qif.Accounts {
+		if len(account.Transactions) == 0 {
+			continue
+		}
+		accountState := state.Accounts[account.Name]
+		if accountState == nil {
+			accountState = &accountExportState{Fingerprints: make(map[string]string)}
+			state.Accounts[account.Name] = accountState
+		}
+		if accountState.Fingerprints == nil {
+			accountState.Fingerprints = make(map[string]string)
+		}
+
+		// Number new fingerprints after those already stored
+		for i, fingerprint := range transactionFingerprints(account.Name, account.Transactions) {
+			for {
+				if _, ok := accountState.Fingerprints[fingerprint]; !ok {
+					break
+				}
+				fingerprint = nextOccurrence(fingerprint)
+			}
+			date := account.Transactions[i].Date.Format("2006-01-02")
+			accountState.Fingerprints[fingerprint] = date
+			if date > accountState.LastExported {
+				accountState.LastExported = date
+			}
+		}
+
+		lastExported, err := time.Parse("2006-01-02", accountState.LastExported)
+		if err != nil {
+			continue
+		}
+		cutoff := lastExported.AddDate(0, 0, -graceDays).Format("2006-01-02")
+		for fingerprint, date := range accountState.Fingerprints {
+			if date < cutoff {
+				delete(accountState.Fingerprints, fingerprint)
+			}
+		}
+	}
+}
+
+// nextOccurrence bumps the occurrence number of a fingerprint.
+func nextOccurrence(fingerprint string) string {
+	var key string
+	var n int
+	fmt.Sscanf(fingerprint, "%16s#%d", &key, &n)
+	return fmt.Sprintf("%s#%d", key, n+1)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exportRun parses a register, drops what the state says was exported and
+// records the rest, like one convert run with -since-state. It returns the
+// payees and amounts exported.
+func exportRun(t *testing.T, state *exportState, register string, graceDays int) []string {
+	t.Helper()
+	qif := parseQIF("!Account\nNChecking\nTBank\n^\n!Type:Bank\n" + register)
+	applyExportState(qif, state, graceDays)
+	var exported []string
+	for _, account := range qif.Accounts {
+		for _, transaction := range account.Transactions {
+			exported = append(exported, transaction.Payee+" "+transaction.Amount.String())
+		}
+	}
+	recordExportState(qif, state, graceDays)
+	return exported
+}
+
+func newExportState() *exportState {
+	return &exportState{Version: 1, Accounts: make(map[string]*accountExportState)}
+}
+
+func checkExported(t *testing.T, run string, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("%s exported %q, want %q", run, got, want)
+	}
+}
+
+func TestTransactionFingerprintsOccurrences(t *testing.T) {
+	qif := parseQIF("!Account\nNChecking\nTBank\n^\n!Type:Bank\n" +
+		"D3/ 5'24\nT-4.50\nPCafe\n^\nD3/ 5'24\nT-4.50\nPCafe\n^\nD3/ 5'24\nT-4.50\nN101\nPCafe\n^\n")
+	fingerprints := transactionFingerprints("Checking", qif.Accounts[0].Transactions)
+	if !strings.HasSuffix(fingerprints[0], "#1") || !strings.HasSuffix(fingerprints[1], "#2") {
+		t.Errorf("identical transactions numbered %q and %q, want #1 and #2", fingerprints[0], fingerprints[1])
+	}
+	if fingerprints[0][:16] != fingerprints[1][:16] {
+		t.Errorf("identical transactions have different keys: %q, %q", fingerprints[0], fingerprints[1])
+	}
+	// The check number is part of the fingerprint
+	if fingerprints[2][:16] == fingerprints[0][:16] || !strings.HasSuffix(fingerprints[2], "#1") {
+		t.Errorf("numbered check fingerprint %q, want a new key numbered #1", fingerprints[2])
+	}
+	// Other accounts get their own fingerprints
+	if other := transactionFingerprints("Savings", qif.Accounts[0].Transactions); other[0] == fingerprints[0] {
+		t.Errorf("fingerprint does not depend on the account name")
+	}
+	if got := nextOccurrence(fingerprints[1]); got != fingerprints[1][:16]+"#3" {
+		t.Errorf("nextOccurrence(%q) = %q", fingerprints[1], got)
+	}
+}
+
+func TestExportStateRepeatedTransactions(t *testing.T) {
+	state := newExportState()
+	twoCoffees := "D3/ 5'24\nT-4.50\nPCafe\n^\nD3/ 5'24\nT-4.50\nPCafe\n^\n"
+	checkExported(t, "first run", exportRun(t, state, twoCoffees, 30), "Cafe -4.50", "Cafe -4.50")
+
+	// A third identical purchase on the same day is new
+	threeCoffees := twoCoffees + "D3/ 5'24\nT-4.50\nPCafe\n^\n"
+	checkExported(t, "second run", exportRun(t, state, threeCoffees, 30), "Cafe -4.50")
+	if got := len(state.Accounts["Checking"].Fingerprints); got != 3 {
+		t.Errorf("state holds %d fingerprints, want 3", got)
+	}
+	checkExported(t, "third run", exportRun(t, state, threeCoffees, 30))
+}
+
+func TestExportStateRerunWithSavedState(t *testing.T) {
+	register := "D3/ 1'24\nT-45.20\nPGrocer\n^\nD3/ 5'24\nT2,500.00\nPAcme\n^\n"
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := loadExportState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExported(t, "first run", exportRun(t, state, register, 30), "Grocer -45.20", "Acme 2500.00")
+	if err := saveExportState(stateFile, state); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = loadExportState(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := state.Accounts["Checking"].LastExported; got != "2024-03-05" {
+		t.Errorf("lastExported %q, want 2024-03-05", got)
+	}
+	checkExported(t, "second run", exportRun(t, state, register, 30))
+}
+
+func TestExportStateGraceWindow(t *testing.T) {
+	state := newExportState()
+	checkExported(t, "first run", exportRun(t, state, "D3/ 1'24\nT-10.00\nPGym\n^\nD3/31'24\nT-50.00\nPPower\n^\n", 10), "Gym -10.00", "Power -50.00")
+
+	// Last export was 3/31, so with 10 grace days anything before 3/21 counts
+	// as exported. A late download dated 3/25 is new; one dated 3/10 is not.
+	// The Gym entry was edited after it was exported but is outside the
+	// window, so it is not sent again.
+	register := "D3/ 1'24\nT-12.00\nPGym\n^\nD3/10'24\nT-7.00\nPLate Old\n^\nD3/25'24\nT-8.00\nPLate New\n^\nD3/31'24\nT-50.00\nPPower\n^\n"
+	checkExported(t, "second run", exportRun(t, state, register, 10), "Late New -8.00")
+
+	// Fingerprints older than the window are pruned from the state
+	for _, date := range state.Accounts["Checking"].Fingerprints {
+		if date < "2024-03-21" {
+			t.Errorf("fingerprint dated %s kept after pruning", date)
+		}
+	}
+}
+
+func TestExportStateEditedAmountInGraceWindow(t *testing.T) {
+	state := newExportState()
+	checkExported(t, "first run", exportRun(t, state, "D3/28'24\nT-45.20\nPGrocer\n^\nD3/31'24\nT-50.00\nPPower\n^\n", 30), "Grocer -45.20", "Power -50.00")
+
+	// A changed amount inside the grace window makes a new fingerprint, so
+	// the corrected transaction is exported again
+	checkExported(t, "second run", exportRun(t, state, "D3/28'24\nT-54.20\nPGrocer\n^\nD3/31'24\nT-50.00\nPPower\n^\n", 30), "Grocer -54.20")
+	checkExported(t, "third run", exportRun(t, state, "D3/28'24\nT-54.20\nPGrocer\n^\nD3/31'24\nT-50.00\nPPower\n^\n", 30))
+}
+
+func TestAccountsWithoutNewTransactions(t *testing.T) {
+	state := newExportState()
+	exportRun(t, state, "D3/ 1'24\nT-10.00\nPGym\n^\n", 30)
+	qif := parseQIF("!Account\nNChecking\nTBank\n^\n!Type:Bank\nD3/ 1'24\nT-10.00\nPGym\n^\n!Account\nNSavings\nTBank\n^\n")
+	applyExportState(qif, state, 30)
+	if got := accountsWithoutNewTransactions(qif, state); strings.Join(got, ",") != "Checking" {
+		t.Errorf("accounts without new transactions %q, want Checking", got)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// taxLine is one transaction, or split, in a tax-related category.
+type taxLine struct {
+	Schedule string
+	Category string
+	Account  string
+	T        *qifTransaction
+	Memo     string
+	Amount   money
+}
+
+// runTaxReport is the tax-report subcommand: it totals a year's transactions
+// in tax-related categories by tax schedule line.
+func runTaxReport(args []string) {
+	taxCmd := flag.NewFlagSet("tax-report", flag.ExitOnError)
+	var inputFile inputFileList
+	taxCmd.Var(&inputFile, "inputfile", "inputfile (repeat or use a glob for several files)")
+	year := taxCmd.Int("year", time.Now().Year()-1, "tax year")
+	outputFile := taxCmd.String("outputfile", "taxReport.csv", "totals by tax schedule line and category")
+	detailFile := taxCmd.String("detailfile", "taxDetail.csv", "contributing transactions")
+	taxCmd.Parse(args)
+	fmt.Println("subcommand 'tax-report'")
+	fmt.Println("	inputfile:", inputFile.String())
+	fmt.Println("	year:", *year)
+	fmt.Println("	outputfile:", *outputFile)
+	fmt.Println("	detailfile:", *detailFile)
+
+	qif, err := loadQIFFiles(inputFile, nil)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+
+	lines := collectTaxLines(qif, *year)
+	err = writeTaxSummary(*outputFile, lines)
+	if err != nil {
+		fmt.Println("Error writing tax report:", err)
+		return
+	}
+	err = writeTaxDetail(*detailFile, lines)
+	if err != nil {
+		fmt.Println("Error writing tax detail:", err)
+		return
+	}
+	fmt.Println("Tax report written:", *outputFile, "transactions:", len(lines))
+}
+
+// taxCategory finds the category record for a category, falling back to
+// the nearest parent in the "Parent:Child" hierarchy that has one.
+func taxCategory(name string, categories map[string]*qifCategory) *qifCategory {
+	for {
+		if category := categories[name]; category != nil {
+			return category
+		}
+		colon := strings.LastIndex(name, ":")
+		if colon < 0 {
+			return nil
+		}
+		name = name[:colon]
+	}
+}
+
+// collectTaxLines returns the transactions and splits dated in year whose
+// category is marked tax related (T) in the category list.
+func collectTaxLines(qif *qifFile, year int) []taxLine {
+	categories := make(map[string]*qifCategory)
+	for _, category := range qif.Categories {
+		categories[category.Name] = category
+	}
+
+	var lines []taxLine
+	add := func(account string, t *qifTransaction, value string, memo string, amount money) {
+		name, _ := splitCategoryAndTag(value)
+		category := taxCategory(name, categories)
+		if category == nil || !category.TaxRelated {
+			return
+		}
+		lines = append(lines, taxLine{Schedule: category.TaxSchedule, Category: name, Account: account, T: t, Memo: memo, Amount: amount})
+	}
+	for _, account := range qif.Accounts {
+		for _, t := range account.Transactions {
+			if t.Date.Year() != year {
+				continue
+			}
+			if len(t.Splits) == 0 {
+				add(account.Name, t, t.Category, t.Memo, t.Amount)
+			}
+			for _, split := range t.Splits {
+				memo := split.Memo
+				if memo == "" {
+					memo = t.Memo
+				}
+				add(account.Name, t, split.Category, memo, split.Amount)
+			}
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].Schedule != lines[j].Schedule {
+			return lines[i].Schedule < lines[j].Schedule
+		}
+		if lines[i].Category != lines[j].Category {
+			return lines[i].Category < lines[j].Category
+		}
+		return lines[i].T.Date.Before(lines[j].T.Date)
+	})
+	return lines
+}
+
+// taxScheduleName labels categories that are tax related but have no R line.
+func taxScheduleName(schedule string) string {
+	if schedule == "" {
+		return "(no schedule)"
+	}
+	return schedule
+}
+
+// writeTaxSummary writes a row per schedule line and category and a total
+// row after each schedule line.
+func writeTaxSummary(outputFileName string, lines []taxLine) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Tax Schedule", "Category", "Transactions", "Total"})
+	for i := 0; i < len(lines); {
+		schedule := lines[i].Schedule
+		var scheduleTotal money
+		scheduleCount := 0
+		for i < len(lines) && lines[i].Schedule == schedule {
+			category := lines[i].Category
+			var total money
+			count := 0
+			for i < len(lines) && lines[i].Schedule == schedule && lines[i].Category == category {
+				total += lines[i].Amount
+				count++
+				i++
+			}
+			writer.Write([]string{taxScheduleName(schedule), category, fmt.Sprint(count), total.String()})
+			scheduleTotal += total
+			scheduleCount += count
+		}
+		writer.Write([]string{taxScheduleName(schedule), "Total", fmt.Sprint(scheduleCount), scheduleTotal.String()})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeTaxDetail(outputFileName string, lines []taxLine) error {
+	file, err := os.Create(outputFileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"Tax Schedule", "Category", "Account", "Date", "Number", "Payee", "Memo", "Amount"})
+	for _, line := range lines {
+		writer.Write([]string{
+			taxScheduleName(line.Schedule),
+			line.Category,
+			line.Account,
+			line.T.Date.Format("2006-01-02"),
+			line.T.Number,
+			line.T.Payee,
+			line.Memo,
+			line.Amount.String(),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var xlsxHeader = []string{"Date", "Account", "Payee", "Category", "Tag", "Number", "Cleared", "Memo", "Amount"}
+
+// xlsxStyles are the style ids shared by every sheet.
+type xlsxStyles struct {
+	header   int
+	date     int
+	currency int
+	total    int
+}
+
+// writeXLSXFile writes a Summary sheet of per-account totals, an All sheet
+// and one sheet per account. Dates and amounts are typed cells and check
+// numbers are text, so Excel keeps their leading zeros.
+func writeXLSXFile(qif *qifFile, accountMapping map[string]string, outputFileName string) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	var styles xlsxStyles
+	var err error
+	currencyFormat := "$#,##0.00;[Red]-$#,##0.00"
+	dateFormat := "yyyy-mm-dd"
+	if styles.header, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}}); err != nil {
+		return err
+	}
+	if styles.date, err = f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat}); err != nil {
+		return err
+	}
+	if styles.currency, err = f.NewStyle(&excelize.Style{CustomNumFmt: &currencyFormat}); err != nil {
+		return err
+	}
+	if styles.total, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}, CustomNumFmt: &currencyFormat}); err != nil {
+		return err
+	}
+
+	if err = f.SetSheetName("Sheet1", "Summary"); err != nil {
+		return err
+	}
+	if _, err = f.NewSheet("All"); err != nil {
+		return err
+	}
+
+	used := map[string]bool{"summary": true, "all": true}
+	type accountSheet struct {
+		sheet   string
+		name    string
+		account *qifAccount
+	}
+	var sheets []accountSheet
+	var all [][]interface{}
+	for _, account := range qif.Accounts {
+		if len(account.Transactions) == 0 {
+			continue
+		}
+		name := ofxAccountName(account.Name, accountMapping)
+		sheet := xlsxSheetName(name, used)
+		if _, err = f.NewSheet(sheet); err != nil {
+			return err
+		}
+		sheets = append(sheets, accountSheet{sheet: sheet, name: name, account: account})
+
+		var rows [][]interface{}
+		for _, t := range account.Transactions {
+			rows = append(rows, xlsxTransactionRow(name, t))
+		}
+		all = append(all, rows...)
+		if err = writeXLSXTransactions(f, sheet, rows, styles); err != nil {
+			return err
+		}
+	}
+	if err = writeXLSXTransactions(f, "All", all, styles); err != nil {
+		return err
+	}
+
+	// Summary of inflows, outflows and net per account
+	summaryHeader := []string{"Account", "Type", "Transactions", "Inflows", "Outflows", "Net"}
+	for i, title := range summaryHeader {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue("Summary", cell, title)
+	}
+	f.SetCellStyle("Summary", "A1", "F1", styles.header)
+	var count int
+	var inflows, outflows money
+	row := 2
+	for _, s := range sheets {
+		var in, out money
+		for _, t := range s.account.Transactions {
+			if t.Amount > 0 {
+				in += t.Amount
+			} else {
+				out += t.Amount
+			}
+		}
+		count += len(s.account.Transactions)
+		inflows += in
+		outflows += out
+		f.SetSheetRow("Summary", fmt.Sprintf("A%d", row), &[]interface{}{s.name, s.account.Type, len(s.account.Transactions), in.float(), out.float(), (in + out).float()})
+		f.SetCellHyperLink("Summary", fmt.Sprintf("A%d", row), "'"+s.sheet+"'!A1", "Location")
+		f.SetCellStyle("Summary", fmt.Sprintf("D%d", row), fmt.Sprintf("F%d", row), styles.currency)
+		row++
+	}
+	f.SetSheetRow("Summary", fmt.Sprintf("A%d", row), &[]interface{}{"Total", "", count, inflows.float(), outflows.float(), (inflows + outflows).float()})
+	f.SetCellStyle("Summary", fmt.Sprintf("A%d", row), fmt.Sprintf("F%d", row), styles.total)
+	f.SetColWidth("Summary", "A", "B", 24)
+	f.SetColWidth("Summary", "C", "F", 14)
+	f.SetPanes("Summary", &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+
+	if err = f.SaveAs(outputFileName); err != nil {
+		return err
+	}
+	fmt.Println("XLSX file written:", outputFileName, "sheets:", len(sheets)+2)
+	return nil
+}
+
+func xlsxTransactionRow(account string, t *qifTransaction) []interface{} {
+	category, tag := splitCategoryAndTag(t.Category)
+	var date interface{} = t.RawDate
+	if !t.Date.IsZero() {
+		date = t.Date
+	}
+	return []interface{}{date, account, t.Payee, category, tag, t.Number, t.Cleared, t.Memo, t.Amount.float()}
+}
+
+// writeXLSXTransactions fills a register sheet with a frozen, filtered
+// header row.
+func writeXLSXTransactions(f *excelize.File, sheet string, rows [][]interface{}, styles xlsxStyles) error {
+	for i, title := range xlsxHeader {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheet, cell, title)
+	}
+	f.SetCellStyle(sheet, "A1", "I1", styles.header)
+
+	for i, values := range rows {
+		row := i + 2
+		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", row), &values); err != nil {
+			return err
+		}
+		// Check numbers stay text so leading zeros survive
+		if number, _ := values[5].(string); number != "" {
+			f.SetCellStr(sheet, fmt.Sprintf("F%d", row), number)
+		}
+	}
+	last := len(rows) + 1
+	f.SetCellStyle(sheet, "A2", fmt.Sprintf("A%d", last), styles.date)
+	f.SetCellStyle(sheet, "I2", fmt.Sprintf("I%d", last), styles.currency)
+
+	f.SetColWidth(sheet, "A", "A", 12)
+	f.SetColWidth(sheet, "B", "D", 24)
+	f.SetColWidth(sheet, "H", "H", 32)
+	f.SetColWidth(sheet, "I", "I", 14)
+	if err := f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"}); err != nil {
+		return err
+	}
+	return f.AutoFilter(sheet, fmt.Sprintf("A1:I%d", last), nil)
+}
+
+// xlsxSheetName makes a unique, valid worksheet name: at most 31 characters
+// and none of : \ / ? * [ ].
+func xlsxSheetName(name string, used map[string]bool) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?*[]`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	cleaned = strings.Trim(cleaned, "'")
+	if cleaned == "" {
+		cleaned = "Account"
+	}
+	base := []rune(cleaned)
+	if len(base) > 31 {
+		base = base[:31]
+	}
+	sheet := string(base)
+	for n := 2; used[strings.ToLower(sheet)]; n++ {
+		suffix := fmt.Sprintf(" (%d)", n)
+		trimmed := base
+		if len(trimmed)+len(suffix) > 31 {
+			trimmed = trimmed[:31-len(suffix)]
+		}
+		sheet = string(trimmed) + suffix
+	}
+	used[strings.ToLower(sheet)] = true
+	return sheet
+}